@@ -0,0 +1,531 @@
+package Builtins
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Archive formats.
+const (
+	FormatZip   = "zip"
+	FormatTar   = "tar"
+	FormatTarGz = "tar.gz"
+)
+
+// DeterministicMtime is the entry time used by deterministic
+// archives unless --mtime is given. It is the earliest time a zip
+// file can record.
+var DeterministicMtime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func init() {
+	Register("archive", archiveBuiltin)
+	Register("extract", extractBuiltin)
+}
+
+type archiveOpts struct {
+	format        string
+	level         int
+	exclude       patternList
+	modes         bool
+	deterministic bool
+	mtime         string
+}
+
+// formatOf returns the explicit format or derives it from the
+// archive file name.
+func formatOf(explicit, name string) (string, error) {
+	if explicit != "" {
+		switch explicit {
+		case FormatZip, FormatTar, FormatTarGz:
+			return explicit, nil
+		case "tgz":
+			return FormatTarGz, nil
+		}
+		return "", fmt.Errorf("invalid --format %q: use zip, tar or tar.gz", explicit)
+	}
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return FormatZip, nil
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return FormatTarGz, nil
+	case strings.HasSuffix(lower, ".tar"):
+		return FormatTar, nil
+	}
+	return "", fmt.Errorf("cannot tell the format of %s; use --format", name)
+}
+
+func excluded(exclude patternList, name string) bool {
+	for _, x := range exclude {
+		if m, _ := path.Match(x, path.Base(name)); m {
+			return true
+		}
+		if m, _ := path.Match(x, name); m {
+			return true
+		}
+	}
+	return false
+}
+
+// archEntry is a file or directory to be archived. name is the
+// slash separated path inside the archive.
+type archEntry struct {
+	name string
+	path string
+	info os.FileInfo
+}
+
+// collectEntries globs the selections. Every match is stored under
+// its base name; directories are added recursively.
+func collectEntries(ctx context.Context, env Env, res *Result, o *archiveOpts, patterns []string) ([]archEntry, error) {
+	var entries []archEntry
+	for _, root := range expand(env, res, "archive", patterns) {
+		parent := filepath.Dir(root)
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				res.addErr("archive", p, err)
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			rel, _ := filepath.Rel(parent, p)
+			name := filepath.ToSlash(rel)
+			if excluded(o.exclude, name) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				res.addErr("archive", p, err)
+				return nil
+			}
+			if !info.Mode().IsRegular() && !info.IsDir() {
+				res.addErr("archive", p, errors.New("not a regular file or directory"))
+				return nil
+			}
+			entries = append(entries, archEntry{name: name, path: p, info: info})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if o.deterministic {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	}
+	return entries, nil
+}
+
+// entryMeta returns the time and mode recorded for an entry.
+func (o *archiveOpts) entryMeta(e archEntry, fixed time.Time) (time.Time, fs.FileMode) {
+	mtime := e.info.ModTime()
+	if o.deterministic {
+		mtime = fixed
+	}
+	mode := e.info.Mode().Perm()
+	if !o.modes {
+		mode = 0644
+		if e.info.IsDir() {
+			mode = 0755
+		}
+	}
+	return mtime, mode
+}
+
+// archiveBuiltin: archive [--format zip|tar|tar.gz] [--level N]
+//
+//	[--exclude PATTERNS]... [--modes=false] [--deterministic]
+//	[--mtime RFC3339] ARCHIVE PATH...
+//
+// Creates ARCHIVE from the globbed PATHs and writes a manifest of
+// its entries (SHA-256, size, mode, name) to the job log.
+func archiveBuiltin(ctx context.Context, env Env, args []string) (*Result, error) {
+	o := &archiveOpts{}
+	flags := newFlagSet("archive", env)
+	flags.StringVar(&o.format, "format", "", "zip, tar or tar.gz; default from the archive name")
+	flags.IntVar(&o.level, "level", flate.DefaultCompression, "compression level, 0 (none) to 9 (best)")
+	flags.Var(&o.exclude, "exclude", "skip files and directories matching these patterns")
+	flags.BoolVar(&o.modes, "modes", true, "record file permission bits")
+	flags.BoolVar(&o.deterministic, "deterministic", false, "sort entries and use a fixed time and owner")
+	flags.StringVar(&o.mtime, "mtime", "", "entry time for --deterministic, RFC 3339")
+	if err := flags.Parse(args); err != nil {
+		return usageErr("archive: %v", err)
+	}
+	if flags.NArg() < 2 {
+		return usageErr("archive: usage: archive [options] ARCHIVE PATH...")
+	}
+	if o.level < flate.HuffmanOnly || o.level > flate.BestCompression {
+		return usageErr("archive: invalid --level %d", o.level)
+	}
+	fixed := DeterministicMtime
+	if o.mtime != "" {
+		t, err := time.Parse(time.RFC3339, o.mtime)
+		if err != nil {
+			return usageErr("archive: invalid --mtime: %v", err)
+		}
+		fixed = t
+	}
+	target := resolve(env, flags.Arg(0))
+	format, err := formatOf(o.format, target)
+	if err != nil {
+		return usageErr("archive: %v", err)
+	}
+
+	res := &Result{}
+	entries, err := collectEntries(ctx, env, res, o, flags.Args()[1:])
+	if err != nil {
+		return res, err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		res.addErr("archive", target, err)
+		return finish(res)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*")
+	if err != nil {
+		res.addErr("archive", target, err)
+		return finish(res)
+	}
+	defer os.Remove(tmp.Name())
+
+	fmt.Fprintf(env.Log, "    manifest of %s:\n", target)
+	if format == FormatZip {
+		err = writeZip(ctx, tmp, entries, o, fixed, env.Log, res)
+	} else {
+		err = writeTar(ctx, tmp, entries, o, fixed, format == FormatTarGz, env.Log, res)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), target)
+	}
+	if err != nil {
+		res.addErr("archive", target, err)
+		if ctx.Err() != nil {
+			return res, ctx.Err()
+		}
+	}
+	return finish(res)
+}
+
+// copyHashed copies a file into w and logs its manifest line.
+func copyHashed(w io.Writer, e archEntry, mode fs.FileMode, log io.Writer, res *Result) error {
+	f, err := os.Open(e.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, h), f)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(log, "    %s  %12d  %04o  %s\n", hex.EncodeToString(h.Sum(nil)), n, mode, e.name)
+	res.add(FileResult{Op: "archive", Src: e.path, Dst: e.name, Bytes: n})
+	return nil
+}
+
+func writeZip(ctx context.Context, out io.Writer, entries []archEntry, o *archiveOpts, fixed time.Time, log io.Writer, res *Result) error {
+	zw := zip.NewWriter(out)
+	zw.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, o.level)
+	})
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		mtime, mode := o.entryMeta(e, fixed)
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate, Modified: mtime.UTC()}
+		if o.level == flate.NoCompression {
+			hdr.Method = zip.Store
+		}
+		if e.info.IsDir() {
+			hdr.Name += "/"
+			hdr.Method = zip.Store
+			hdr.SetMode(fs.ModeDir | mode)
+			if _, err := zw.CreateHeader(hdr); err != nil {
+				return err
+			}
+			continue
+		}
+		hdr.SetMode(mode)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if err := copyHashed(w, e, mode, log, res); err != nil {
+			res.addErr("archive", e.path, err)
+		}
+	}
+	return zw.Close()
+}
+
+func writeTar(ctx context.Context, out io.Writer, entries []archEntry, o *archiveOpts, fixed time.Time, gz bool, log io.Writer, res *Result) error {
+	var gzw *gzip.Writer
+	if gz {
+		var err error
+		if gzw, err = gzip.NewWriterLevel(out, o.level); err != nil {
+			return err
+		}
+		if o.deterministic {
+			gzw.ModTime = fixed
+		}
+		out = gzw
+	}
+	tw := tar.NewWriter(out)
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		mtime, mode := o.entryMeta(e, fixed)
+		hdr, err := tar.FileInfoHeader(e.info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = e.name
+		hdr.Mode = int64(mode)
+		hdr.ModTime = mtime
+		hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+		hdr.Format = tar.FormatPAX
+		if o.deterministic {
+			// PAX still, so that long names fit, with nothing
+			//   recorded of the machine or the owner.
+			hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+			hdr.PAXRecords = nil
+		}
+		if e.info.IsDir() {
+			hdr.Name += "/"
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if err := copyHashed(tw, e, mode, log, res); err != nil {
+			// The header promised the file's size; the archive
+			//   cannot be completed.
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if gzw != nil {
+		return gzw.Close()
+	}
+	return nil
+}
+
+// safeJoin places an archive entry below dir, rejecting names which
+// would escape it.
+func safeJoin(dir, name string) (string, error) {
+	slashed := strings.ReplaceAll(name, "\\", "/")
+	clean := path.Clean(slashed)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") ||
+		path.IsAbs(slashed) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("unsafe entry name %q", name)
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
+
+// extractBuiltin: extract [--format zip|tar|tar.gz] [--exclude PATTERNS]...
+//
+//	[--modes=false] ARCHIVE DIR
+//
+// Extracts ARCHIVE below DIR, restoring entry times and, unless
+// --modes=false, permission bits. A manifest of the extracted files
+// is written to the job log.
+func extractBuiltin(ctx context.Context, env Env, args []string) (*Result, error) {
+	o := &archiveOpts{}
+	flags := newFlagSet("extract", env)
+	flags.StringVar(&o.format, "format", "", "zip, tar or tar.gz; default from the archive name")
+	flags.Var(&o.exclude, "exclude", "skip entries matching these patterns")
+	flags.BoolVar(&o.modes, "modes", true, "restore file permission bits")
+	if err := flags.Parse(args); err != nil {
+		return usageErr("extract: %v", err)
+	}
+	if flags.NArg() != 2 {
+		return usageErr("extract: usage: extract [options] ARCHIVE DIR")
+	}
+	src, dir := resolve(env, flags.Arg(0)), resolve(env, flags.Arg(1))
+	format, err := formatOf(o.format, src)
+	if err != nil {
+		return usageErr("extract: %v", err)
+	}
+	res := &Result{}
+	fmt.Fprintf(env.Log, "    manifest of %s:\n", src)
+	x := &extractor{ctx: ctx, dir: dir, o: o, log: env.Log, res: res}
+	if format == FormatZip {
+		err = x.zip(src)
+	} else {
+		err = x.tar(src, format == FormatTarGz)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return res, ctx.Err()
+		}
+		res.addErr("extract", src, err)
+	}
+	x.restoreDirTimes()
+	return finish(res)
+}
+
+type extractor struct {
+	ctx  context.Context
+	dir  string
+	o    *archiveOpts
+	log  io.Writer
+	res  *Result
+	dirs []archDir
+}
+
+type archDir struct {
+	path  string
+	mtime time.Time
+}
+
+// entry extracts one entry; open is nil for directories.
+func (x *extractor) entry(name string, mode fs.FileMode, mtime time.Time, isDir bool, open func() (io.ReadCloser, error)) error {
+	if err := x.ctx.Err(); err != nil {
+		return err
+	}
+	name = strings.TrimSuffix(name, "/")
+	if excluded(x.o.exclude, name) {
+		return nil
+	}
+	target, err := safeJoin(x.dir, name)
+	if err != nil {
+		x.res.addErr("extract", name, err)
+		return nil
+	}
+	perm := mode.Perm()
+	if !x.o.modes {
+		perm = 0644
+	}
+	if isDir {
+		if !x.o.modes {
+			perm = 0755
+		}
+		if err := os.MkdirAll(target, perm|0700); err != nil {
+			x.res.addErr("extract", name, err)
+		}
+		x.dirs = append(x.dirs, archDir{target, mtime})
+		return nil
+	}
+	rc, err := open()
+	if err != nil {
+		x.res.addErr("extract", name, err)
+		return nil
+	}
+	defer rc.Close()
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		x.res.addErr("extract", name, err)
+		return nil
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		x.res.addErr("extract", name, err)
+		return nil
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), rc)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && x.o.modes {
+		err = os.Chmod(target, perm)
+	}
+	if err == nil {
+		err = os.Chtimes(target, mtime, mtime)
+	}
+	if err != nil {
+		x.res.addErr("extract", name, err)
+		return nil
+	}
+	fmt.Fprintf(x.log, "    %s  %12d  %04o  %s\n", hex.EncodeToString(h.Sum(nil)), n, perm, name)
+	x.res.add(FileResult{Op: "extract", Src: name, Dst: target, Bytes: n})
+	return nil
+}
+
+func (x *extractor) zip(src string) error {
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	for _, zf := range zr.File {
+		if zf.Mode()&fs.ModeSymlink != 0 {
+			x.res.addErr("extract", zf.Name, errors.New("symbolic links are not extracted"))
+			continue
+		}
+		if err := x.entry(zf.Name, zf.Mode(), zf.Modified, zf.FileInfo().IsDir(), zf.Open); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *extractor) tar(src string, gz bool) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if gz {
+		gzr, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gzr.Close()
+		r = gzr
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeReg:
+		default:
+			x.res.addErr("extract", hdr.Name, fmt.Errorf("unsupported entry type %q", hdr.Typeflag))
+			continue
+		}
+		open := func() (io.ReadCloser, error) { return io.NopCloser(tr), nil }
+		if err := x.entry(hdr.Name, fs.FileMode(hdr.Mode), hdr.ModTime, hdr.Typeflag == tar.TypeDir, open); err != nil {
+			return err
+		}
+	}
+}
+
+// restoreDirTimes sets directory times, deepest first, after their
+// contents have been written.
+func (x *extractor) restoreDirTimes() {
+	for i := len(x.dirs) - 1; i >= 0; i-- {
+		os.Chtimes(x.dirs[i].path, x.dirs[i].mtime, x.dirs[i].mtime)
+	}
+}
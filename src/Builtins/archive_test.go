@@ -0,0 +1,134 @@
+package Builtins
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	for _, name := range []string{"out.zip", "out.tar", "out.tar.gz"} {
+		t.Log("Given an archive named " + name + ":")
+		{
+			dir := t.TempDir()
+			old := time.Date(2021, 5, 6, 7, 8, 10, 0, time.UTC)
+			writeFile(t, filepath.Join(dir, "cfg", "a.json"), "aa", old)
+			writeFile(t, filepath.Join(dir, "cfg", "sub", "b.cson"), "bbb", old)
+			writeFile(t, filepath.Join(dir, "cfg", "skip.tmp"), "x", old)
+			os.Chmod(filepath.Join(dir, "cfg", "a.json"), 0600)
+
+			var log bytes.Buffer
+			res, err := Run(context.Background(), Env{Dir: dir, Log: &log},
+				[]string{"archive", "--exclude", "*.tmp", name, "cfg"})
+			if err != nil || res.ExitCode != ExitOK || res.Count != 2 || res.Bytes != 5 {
+				t.Fatalf("archive: %v %s", err, res.Summary())
+			}
+			if !strings.Contains(log.String(), "cfg/sub/b.cson") || strings.Contains(log.String(), "skip.tmp") {
+				t.Errorf("Unexpected manifest:\n%s", log.String())
+			}
+
+			res = run(t, dir, "extract", name, "restored")
+			if res.ExitCode != ExitOK || res.Count != 2 {
+				t.Fatalf("extract: %s", res.Summary())
+			}
+			fi, err := os.Stat(filepath.Join(dir, "restored", "cfg", "a.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !fi.ModTime().Equal(old) {
+				t.Errorf("Time not restored: %v", fi.ModTime())
+			}
+			if runtime.GOOS != "windows" && fi.Mode().Perm() != 0600 {
+				t.Errorf("Mode not restored: %v", fi.Mode())
+			}
+			if _, err := os.Stat(filepath.Join(dir, "restored", "cfg", "skip.tmp")); err == nil {
+				t.Error("Excluded file was archived")
+			}
+		}
+	}
+}
+
+func TestArchiveDeterministic(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "src", "z.txt"), "z", time.Now())
+	writeFile(t, filepath.Join(dir, "src", "a.txt"), "a", time.Now().Add(-time.Hour))
+
+	for _, name := range []string{"one.tar.gz", "one.zip"} {
+		run(t, dir, "archive", "--deterministic", name, "src")
+		first, _ := os.ReadFile(filepath.Join(dir, name))
+		os.Chtimes(filepath.Join(dir, "src", "z.txt"), time.Now().Add(time.Hour), time.Now().Add(time.Hour))
+		run(t, dir, "archive", "--deterministic", name, "src")
+		second, _ := os.ReadFile(filepath.Join(dir, name))
+		if len(first) == 0 || !bytes.Equal(first, second) {
+			t.Errorf("%s: deterministic archives differ", name)
+		}
+	}
+
+	t.Log("Given a name too long for a ustar header:")
+	{
+		long := filepath.Join("src", strings.Repeat("d", 90), strings.Repeat("f", 90)+".txt")
+		writeFile(t, filepath.Join(dir, long), "x", time.Now())
+		if res := run(t, dir, "archive", "--deterministic", "long.tar", "src"); res.ExitCode != ExitOK {
+			t.Fatalf("archive: %s", res.Summary())
+		}
+		f, err := os.Open(filepath.Join(dir, "long.tar"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		tr := tar.NewReader(f)
+		var names []string
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if hdr.Uid != 0 || hdr.Gid != 0 || hdr.Uname != "" || hdr.Gname != "" ||
+				!hdr.ModTime.Equal(DeterministicMtime) || !hdr.AccessTime.IsZero() || !hdr.ChangeTime.IsZero() {
+				t.Errorf("%s: owner or times recorded: %+v", hdr.Name, hdr)
+			}
+			names = append(names, hdr.Name)
+		}
+		if want := filepath.ToSlash(long); !strings.Contains(strings.Join(names, "\n"), want) {
+			t.Errorf("Entries %v, want %s", names, want)
+		}
+	}
+}
+
+func TestExtractRejectsUnsafeNames(t *testing.T) {
+	for _, name := range []string{"../evil", "/etc/evil", "a/../../evil", "."} {
+		if _, err := safeJoin("/tmp/x", name); err == nil {
+			t.Errorf("%q was accepted", name)
+		}
+	}
+	if p, err := safeJoin("/tmp/x", "a/./b/../c"); err != nil || p != filepath.Join("/tmp/x", "a", "c") {
+		t.Errorf("Got %q, %v", p, err)
+	}
+}
+
+func TestArchiveUsage(t *testing.T) {
+	env := Env{Dir: t.TempDir(), Log: io.Discard}
+	for _, argv := range [][]string{
+		{"archive", "out.zip"},
+		{"archive", "out.rar", "x"},
+		{"archive", "--level", "12", "out.zip", "x"},
+		{"extract", "in.zip"},
+	} {
+		if res, err := Run(context.Background(), env, argv); err == nil || res.ExitCode != ExitUsage {
+			t.Errorf("%v: expected usage error", argv)
+		}
+	}
+	os.WriteFile(filepath.Join(env.Dir, "file"), nil, 0644)
+	if res := run(t, env.Dir, "archive", "file/out.zip", "file"); res.ExitCode != ExitFileError {
+		t.Errorf("Unwritable archive: exit code %d, expected %d", res.ExitCode, ExitFileError)
+	}
+}
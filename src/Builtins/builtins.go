@@ -0,0 +1,158 @@
+package Builtins
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"go_cmdrX/src/stringmgr/printfmtr"
+)
+
+// CmdType is the cmd_type of jobs which run a builtin. The first
+// command element names the builtin, the rest are its arguments.
+const CmdType = "Builtin"
+
+// Builtin exit codes. Builtins with their own conventions, such as
+// sync, document theirs.
+const (
+	ExitOK        = 0
+	ExitFileError = 1
+	ExitUsage     = 2
+)
+
+// FileResult is the outcome of one file operation.
+type FileResult struct {
+	Src   string `json:"src,omitempty"`
+	Dst   string `json:"dst,omitempty"`
+	Op    string `json:"op"`
+	Bytes int64  `json:"bytes"`
+	Err   string `json:"error,omitempty"`
+}
+
+// Result is the structured outcome of a builtin.
+type Result struct {
+	Builtin  string       `json:"builtin"`
+	ExitCode int          `json:"exit_code"`
+	Files    []FileResult `json:"files"`
+	Count    int          `json:"count"`
+	Bytes    int64        `json:"bytes"`
+	Skipped  int          `json:"skipped"`
+	Errors   int          `json:"errors"`
+}
+
+func (r *Result) add(fr FileResult) {
+	r.Files = append(r.Files, fr)
+	if fr.Err != "" {
+		r.Errors++
+		return
+	}
+	switch fr.Op {
+	case "skip", "mismatch", "extra":
+		r.Skipped++
+		return
+	}
+	r.Count++
+	r.Bytes += fr.Bytes
+}
+
+func (r *Result) addErr(op, src string, err error) {
+	r.add(FileResult{Op: op, Src: src, Err: err.Error()})
+}
+
+// successMax holds the highest success exit code of builtins with
+// their own conventions, such as sync. Other builtins succeed only
+// with ExitOK.
+var successMax = map[string]int{}
+
+// Failed reports whether the exit code is a failure of the builtin.
+func (r *Result) Failed() bool {
+	return r.ExitCode > successMax[r.Builtin]
+}
+
+// Summary returns a one line account of the result.
+func (r *Result) Summary() string {
+	return fmt.Sprintf("%s: %s file(s), %s bytes, %d skipped, %d error(s), exit code %d", r.Builtin,
+		printfmtr.CommasInt(r.Count), printfmtr.CommasInt64(r.Bytes), r.Skipped, r.Errors, r.ExitCode)
+}
+
+// Env is the context a builtin runs in.
+type Env struct {
+	// Dir resolves relative paths.
+	Dir string
+	// Log receives progress messages and per file errors.
+	Log io.Writer
+}
+
+// Func implements a builtin.
+type Func func(ctx context.Context, env Env, args []string) (*Result, error)
+
+var registry = map[string]Func{}
+
+// Register adds a builtin. Registering a name twice panics.
+func Register(name string, f Func) {
+	name = strings.ToLower(name)
+	if _, dup := registry[name]; dup {
+		panic("Builtins: duplicate builtin " + name)
+	}
+	registry[name] = f
+}
+
+// Names returns the registered builtin names in order.
+func Names() []string {
+	var names []string
+	for n := range registry {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsBuiltinType reports whether a job's cmd_type selects a builtin.
+func IsBuiltinType(cmdType string) bool {
+	return strings.EqualFold(cmdType, CmdType)
+}
+
+// Run executes the builtin named by argv[0]. Failures of single
+// files are recorded in the result and its exit code; an error is
+// returned only for bad arguments or cancellation.
+func Run(ctx context.Context, env Env, argv []string) (*Result, error) {
+	if len(argv) == 0 {
+		return &Result{ExitCode: ExitUsage}, fmt.Errorf("no builtin named")
+	}
+	name := strings.ToLower(argv[0])
+	f, ok := registry[name]
+	if !ok {
+		return &Result{Builtin: name, ExitCode: ExitUsage},
+			fmt.Errorf("unknown builtin %q; available: %s", argv[0], strings.Join(Names(), ", "))
+	}
+	res, err := f(ctx, env, argv[1:])
+	if res == nil {
+		res = &Result{ExitCode: ExitUsage}
+	}
+	res.Builtin = name
+	return res, err
+}
+
+// newFlagSet returns a flag set which reports usage errors to the
+// builtin's log instead of exiting.
+func newFlagSet(name string, env Env) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(env.Log)
+	return fs
+}
+
+// usageErr builds the result of a builtin invoked with bad arguments.
+func usageErr(format string, a ...interface{}) (*Result, error) {
+	return &Result{ExitCode: ExitUsage}, fmt.Errorf(format, a...)
+}
+
+// finish sets the exit code from the per file errors.
+func finish(res *Result) (*Result, error) {
+	if res.Errors > 0 {
+		res.ExitCode = ExitFileError
+	}
+	return res, nil
+}
@@ -0,0 +1,275 @@
+package Builtins
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Checksum algorithms.
+const (
+	AlgoSHA256 = "sha256"
+	AlgoSHA1   = "sha1"
+	AlgoMD5    = "md5"
+)
+
+func init() {
+	Register("checksum", checksumBuiltin)
+}
+
+func newHash(algo string) (hash.Hash, error) {
+	switch strings.ToLower(algo) {
+	case AlgoSHA256:
+		return sha256.New(), nil
+	case AlgoSHA1:
+		return sha1.New(), nil
+	case AlgoMD5:
+		return md5.New(), nil
+	}
+	return nil, fmt.Errorf("invalid --algo %q: use sha256, sha1 or md5", algo)
+}
+
+// algoForDigest guesses the algorithm of a manifest line from the
+// length of its hex digest.
+func algoForDigest(digest string) string {
+	switch len(digest) {
+	case 2 * sha1.Size:
+		return AlgoSHA1
+	case 2 * md5.Size:
+		return AlgoMD5
+	}
+	return AlgoSHA256
+}
+
+func hashFile(algo, path string) (string, int64, error) {
+	h, err := newHash(algo)
+	if err != nil {
+		return "", 0, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// manifestName returns the name of path in a manifest: relative to
+// base with forward slashes, or absolute when outside base.
+func manifestName(base, path string) string {
+	rel, err := filepath.Rel(base, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// manifestLine formats a line the way sha256sum does, escaping
+// names which hold a backslash or a newline.
+func manifestLine(digest, name string) string {
+	if strings.ContainsAny(name, "\\\n") {
+		name = strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(name)
+		return "\\" + digest + "  " + name + "\n"
+	}
+	return digest + "  " + name + "\n"
+}
+
+// parseManifestLine accepts text ("  ") and binary (" *") lines.
+func parseManifestLine(line string) (digest, name string, err error) {
+	escaped := strings.HasPrefix(line, "\\")
+	if escaped {
+		line = line[1:]
+	}
+	i := strings.IndexByte(line, ' ')
+	if i <= 0 || i+2 > len(line) || (line[i+1] != ' ' && line[i+1] != '*') {
+		return "", "", errors.New("not a checksum line")
+	}
+	digest, name = strings.ToLower(line[:i]), line[i+2:]
+	if _, err := hex.DecodeString(digest); err != nil {
+		return "", "", errors.New("digest is not hexadecimal")
+	}
+	if escaped {
+		name = strings.NewReplacer("\\\\", "\\", "\\n", "\n").Replace(name)
+	}
+	return digest, name, nil
+}
+
+// checksumBuiltin: checksum [--algo sha256|sha1|md5] [--base DIR]
+//
+//	[--exclude PATTERNS]... [-o MANIFEST] PATH...
+//	checksum --check [--base DIR] MANIFEST...
+//
+// Hashes the globbed files and directory trees and writes a
+// manifest in sha256sum format, to MANIFEST or to the job log.
+// Names are relative to --base, the job directory by default.
+// With --check the files listed in the manifests are hashed again
+// and any mismatch or missing file fails the job.
+func checksumBuiltin(ctx context.Context, env Env, args []string) (*Result, error) {
+	var exclude patternList
+	flags := newFlagSet("checksum", env)
+	algo := flags.String("algo", AlgoSHA256, "sha256, sha1 or md5")
+	base := flags.String("base", "", "directory manifest names are relative to")
+	output := flags.String("o", "", "write the manifest to this file")
+	check := flags.Bool("check", false, "verify the files listed in the manifests")
+	flags.Var(&exclude, "exclude", "skip files and directories matching these patterns")
+	if err := flags.Parse(args); err != nil {
+		return usageErr("checksum: %v", err)
+	}
+	if flags.NArg() == 0 {
+		return usageErr("checksum: usage: checksum [options] PATH... or checksum --check MANIFEST...")
+	}
+	if _, err := newHash(*algo); err != nil {
+		return usageErr("checksum: %v", err)
+	}
+	baseDir := env.Dir
+	if baseDir == "" {
+		baseDir = "."
+	}
+	if *base != "" {
+		baseDir = resolve(env, *base)
+	}
+	if *check {
+		return verifyManifests(ctx, env, baseDir, flags.Args())
+	}
+
+	res := &Result{}
+	var manifest strings.Builder
+	target := ""
+	if *output != "" {
+		target = resolve(env, *output)
+	}
+	for _, root := range expand(env, res, "checksum", flags.Args()) {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				res.addErr("checksum", path, err)
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			name := manifestName(baseDir, path)
+			if path != root && excluded(exclude, name) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() || path == target {
+				return nil
+			}
+			digest, n, err := hashFile(*algo, path)
+			if err != nil {
+				res.addErr("checksum", path, err)
+				return nil
+			}
+			manifest.WriteString(manifestLine(digest, name))
+			res.add(FileResult{Op: "checksum", Src: path, Bytes: n})
+			return nil
+		})
+		if err != nil {
+			return res, err
+		}
+	}
+	if target == "" {
+		io.WriteString(env.Log, manifest.String())
+		return finish(res)
+	}
+	if err := writeAtomic(target, []byte(manifest.String())); err != nil {
+		res.addErr("checksum", target, err)
+	} else {
+		fmt.Fprintf(env.Log, "    wrote %s\n", target)
+	}
+	return finish(res)
+}
+
+// writeAtomic replaces path with data through a temporary file.
+func writeAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// verifyManifests checks every line of the manifests. The algorithm
+// of each line follows from the length of its digest.
+func verifyManifests(ctx context.Context, env Env, baseDir string, manifests []string) (*Result, error) {
+	res := &Result{}
+	for _, m := range expand(env, res, "verify", manifests) {
+		f, err := os.Open(m)
+		if err != nil {
+			res.addErr("verify", m, err)
+			continue
+		}
+		sc := bufio.NewScanner(f)
+		for lineNo := 1; sc.Scan(); lineNo++ {
+			if err := ctx.Err(); err != nil {
+				f.Close()
+				return res, err
+			}
+			line := strings.TrimRight(sc.Text(), "\r")
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			digest, name, err := parseManifestLine(line)
+			if err != nil {
+				res.addErr("verify", fmt.Sprintf("%s:%d", m, lineNo), err)
+				continue
+			}
+			path := filepath.FromSlash(name)
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(baseDir, path)
+			}
+			got, n, err := hashFile(algoForDigest(digest), path)
+			switch {
+			case errors.Is(err, fs.ErrNotExist):
+				err = errors.New("missing")
+			case err == nil && got != digest:
+				err = errors.New("checksum mismatch")
+			}
+			if err != nil {
+				fmt.Fprintf(env.Log, "    %s: FAILED (%v)\n", name, err)
+				res.addErr("verify", path, err)
+				continue
+			}
+			fmt.Fprintf(env.Log, "    %s: OK\n", name)
+			res.add(FileResult{Op: "verify", Src: path, Bytes: n})
+		}
+		if err := sc.Err(); err != nil {
+			res.addErr("verify", m, err)
+		}
+		f.Close()
+	}
+	return finish(res)
+}
@@ -0,0 +1,91 @@
+package Builtins
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChecksumManifestAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "src", "a.txt"), "abc", time.Now())
+	writeFile(t, filepath.Join(dir, "src", "sub", "b.txt"), "bbb", time.Now())
+	writeFile(t, filepath.Join(dir, "src", "x.tmp"), "x", time.Now())
+
+	t.Log("Given a tree hashed into a manifest:")
+	{
+		res := run(t, dir, "checksum", "--base", "src", "--exclude", "*.tmp", "-o", "src.sha256", "src")
+		if res.Count != 2 {
+			t.Fatalf("checksum: %s", res.Summary())
+		}
+		data, _ := os.ReadFile(filepath.Join(dir, "src.sha256"))
+		want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad  a.txt\n"
+		if !strings.HasPrefix(string(data), want) || !strings.Contains(string(data), "  sub/b.txt\n") {
+			t.Errorf("Unexpected manifest:\n%s", data)
+		}
+	}
+
+	t.Log("Given a faithful copy:")
+	{
+		run(t, dir, "copy", "-r", "src", "dst")
+		if res := run(t, dir, "checksum", "--check", "--base", "dst", "src.sha256"); res.ExitCode != ExitOK || res.Count != 2 {
+			t.Errorf("verify: %s", res.Summary())
+		}
+	}
+
+	t.Log("Given a changed and a missing file:")
+	{
+		writeFile(t, filepath.Join(dir, "dst", "a.txt"), "abd", time.Now())
+		os.Remove(filepath.Join(dir, "dst", "sub", "b.txt"))
+		var log bytes.Buffer
+		res, err := Run(context.Background(), Env{Dir: dir, Log: &log},
+			[]string{"checksum", "--check", "--base", "dst", "src.sha256"})
+		if err != nil || res.ExitCode != ExitFileError || res.Errors != 2 {
+			t.Errorf("verify: %v %s", err, res.Summary())
+		}
+		if !strings.Contains(log.String(), "a.txt: FAILED (checksum mismatch)") ||
+			!strings.Contains(log.String(), "sub/b.txt: FAILED (missing)") {
+			t.Errorf("Unexpected log:\n%s", log.String())
+		}
+	}
+}
+
+func TestChecksumAlgorithms(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "abc", time.Now())
+	for algo, digest := range map[string]string{
+		AlgoMD5:  "900150983cd24fb0d6963f7d28e17f72",
+		AlgoSHA1: "a9993e364706816aba3e25717850c26c9cd0d89d",
+	} {
+		var log bytes.Buffer
+		if _, err := Run(context.Background(), Env{Dir: dir, Log: &log},
+			[]string{"checksum", "--algo", algo, "a.txt"}); err != nil {
+			t.Fatal(err)
+		}
+		if log.String() != digest+"  a.txt\n" {
+			t.Errorf("%s: got %q", algo, log.String())
+		}
+		os.WriteFile(filepath.Join(dir, algo+".sum"), log.Bytes(), 0644)
+		if res := run(t, dir, "checksum", "--check", algo+".sum"); res.ExitCode != ExitOK {
+			t.Errorf("%s verify: %s", algo, res.Summary())
+		}
+	}
+}
+
+func TestManifestLineEscaping(t *testing.T) {
+	line := manifestLine("00ff", "a\\b\nc")
+	digest, name, err := parseManifestLine(strings.TrimSuffix(line, "\n"))
+	if err != nil || digest != "00ff" || name != "a\\b\nc" {
+		t.Errorf("Got %q %q %v from %q", digest, name, err, line)
+	}
+	if _, name, err := parseManifestLine("00FF *bin.dat"); err != nil || name != "bin.dat" {
+		t.Errorf("Binary mode line: %q %v", name, err)
+	}
+	if _, _, err := parseManifestLine("garbage"); err == nil {
+		t.Error("Expected error for garbage line")
+	}
+}
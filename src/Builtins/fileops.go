@@ -0,0 +1,434 @@
+package Builtins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Overwrite policies for copy and move.
+const (
+	OverwriteAlways = "always"
+	OverwriteNever  = "never"
+	OverwriteNewer  = "newer"
+)
+
+func init() {
+	Register("copy", copyBuiltin)
+	Register("move", moveBuiltin)
+	Register("delete", deleteBuiltin)
+	Register("mkdir", mkdirBuiltin)
+}
+
+// copyOpts are the options shared by copy and move.
+type copyOpts struct {
+	recursive bool
+	overwrite string
+	preserve  bool
+}
+
+func (o copyOpts) validate() error {
+	switch o.overwrite {
+	case OverwriteAlways, OverwriteNever, OverwriteNewer:
+		return nil
+	}
+	return fmt.Errorf("invalid --overwrite %q: use always, never or newer", o.overwrite)
+}
+
+func resolve(env Env, path string) string {
+	if filepath.IsAbs(path) || env.Dir == "" {
+		return path
+	}
+	return filepath.Join(env.Dir, path)
+}
+
+// expand resolves and globs the source arguments. A pattern which
+// matches nothing is reported as a file error.
+func expand(env Env, res *Result, op string, patterns []string) []string {
+	var paths []string
+	for _, p := range patterns {
+		matches, err := filepath.Glob(resolve(env, p))
+		if err != nil {
+			res.addErr(op, p, err)
+			continue
+		}
+		if len(matches) == 0 {
+			res.addErr(op, p, errors.New("no such file or directory"))
+			continue
+		}
+		paths = append(paths, matches...)
+	}
+	return paths
+}
+
+// destFor returns the target path of src. The destination is a
+// directory when it already is one, ends in a separator, or when
+// there are several sources.
+func destFor(src, dst string, many bool) string {
+	if fi, err := os.Stat(dst); (err == nil && fi.IsDir()) || many ||
+		strings.HasSuffix(dst, "/") || strings.HasSuffix(dst, string(filepath.Separator)) {
+		return filepath.Join(dst, filepath.Base(src))
+	}
+	return dst
+}
+
+// copyBuiltin: copy [-r] [--overwrite=always|never|newer] [--preserve] SRC... DST
+func copyBuiltin(ctx context.Context, env Env, args []string) (*Result, error) {
+	var o copyOpts
+	flags := newFlagSet("copy", env)
+	flags.BoolVar(&o.recursive, "r", false, "copy directories recursively")
+	flags.StringVar(&o.overwrite, "overwrite", OverwriteAlways, "always, never or newer")
+	flags.BoolVar(&o.preserve, "preserve", false, "keep modification times of copied files")
+	if err := flags.Parse(args); err != nil {
+		return usageErr("copy: %v", err)
+	}
+	if err := o.validate(); err != nil {
+		return usageErr("copy: %v", err)
+	}
+	if flags.NArg() < 2 {
+		return usageErr("copy: usage: copy [-r] [--overwrite=policy] [--preserve] SRC... DST")
+	}
+	res := &Result{}
+	srcs := expand(env, res, "copy", flags.Args()[:flags.NArg()-1])
+	dst := resolve(env, flags.Arg(flags.NArg()-1))
+	for _, src := range srcs {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		copyTree(ctx, res, src, destFor(src, dst, len(srcs) > 1), o)
+	}
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+	return finish(res)
+}
+
+// copyTree copies a file, or a directory when recursive is set.
+// Symbolic links are followed, but for links to a directory above
+// them, which would be copied without end.
+func copyTree(ctx context.Context, res *Result, src, dst string, o copyOpts) {
+	fi, err := os.Stat(src)
+	if err != nil {
+		res.addErr("copy", src, err)
+		return
+	}
+	if !fi.IsDir() {
+		res.add(copyFile(src, dst, fi, o))
+		return
+	}
+	if !o.recursive {
+		res.addErr("copy", src, errors.New("is a directory (use -r)"))
+		return
+	}
+	err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			res.addErr("copy", path, err)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		rel, _ := filepath.Rel(src, path)
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			if info, err = os.Stat(path); err == nil && info.IsDir() {
+				var to string
+				if to, err = linkTarget(path); err == nil {
+					copyTree(ctx, res, to, target, o)
+					return nil
+				}
+			}
+		}
+		if err != nil {
+			res.addErr("copy", path, err)
+			return nil
+		}
+		if d.IsDir() {
+			if err := os.MkdirAll(target, info.Mode().Perm()|0700); err != nil {
+				res.addErr("mkdir", target, err)
+				return fs.SkipDir
+			}
+			return nil
+		}
+		res.add(copyFile(path, target, info, o))
+		return nil
+	})
+	if err == nil && o.preserve {
+		// Directory times change as files are added, so they are
+		//   restored after the walk.
+		preserveDirTimes(src, dst)
+	}
+}
+
+// linkTarget returns the directory the symbolic link path points
+// to, or an error when that directory holds the link.
+func linkTarget(path string) (string, error) {
+	to, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(to, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.New("symbolic link to a directory above it, not followed")
+	}
+	return to, nil
+}
+
+func preserveDirTimes(src, dst string) {
+	filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			rel, _ := filepath.Rel(src, path)
+			os.Chtimes(filepath.Join(dst, rel), info.ModTime(), info.ModTime())
+		}
+		return nil
+	})
+}
+
+// copyFile copies one regular file, honouring the overwrite policy.
+// The copy keeps the permission bits of the source and, with
+// preserve, its modification time.
+func copyFile(src, dst string, fi os.FileInfo, o copyOpts) FileResult {
+	fr := FileResult{Op: "copy", Src: src, Dst: dst}
+	if skip, err := skipExisting(dst, fi, o.overwrite); err != nil || skip {
+		if err != nil {
+			fr.Err = err.Error()
+		} else {
+			fr.Op = "skip"
+		}
+		return fr
+	}
+	n, err := copyContent(src, dst, fi.Mode().Perm())
+	fr.Bytes = n
+	if err == nil && o.preserve {
+		err = os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+	}
+	if err != nil {
+		fr.Err = err.Error()
+	}
+	return fr
+}
+
+// skipExisting applies the overwrite policy to an existing target.
+func skipExisting(dst string, src os.FileInfo, overwrite string) (bool, error) {
+	di, err := os.Stat(dst)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if di.IsDir() {
+		return false, errors.New("target is a directory")
+	}
+	switch overwrite {
+	case OverwriteNever:
+		return true, nil
+	case OverwriteNewer:
+		return !src.ModTime().After(di.ModTime()), nil
+	}
+	return false, nil
+}
+
+// copyContent writes to a temporary file and renames it into place,
+// so that a failed copy never leaves a truncated target.
+func copyContent(src, dst string, perm os.FileMode) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(tmp, in)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dst)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	return n, nil
+}
+
+// moveBuiltin: move [--overwrite=always|never|newer] SRC... DST
+// Files and directories are renamed; across file systems they are
+// copied with their times preserved and then removed.
+func moveBuiltin(ctx context.Context, env Env, args []string) (*Result, error) {
+	o := copyOpts{recursive: true, preserve: true}
+	flags := newFlagSet("move", env)
+	flags.StringVar(&o.overwrite, "overwrite", OverwriteAlways, "always, never or newer")
+	if err := flags.Parse(args); err != nil {
+		return usageErr("move: %v", err)
+	}
+	if err := o.validate(); err != nil {
+		return usageErr("move: %v", err)
+	}
+	if flags.NArg() < 2 {
+		return usageErr("move: usage: move [--overwrite=policy] SRC... DST")
+	}
+	res := &Result{}
+	srcs := expand(env, res, "move", flags.Args()[:flags.NArg()-1])
+	dst := resolve(env, flags.Arg(flags.NArg()-1))
+	for _, src := range srcs {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		target := destFor(src, dst, len(srcs) > 1)
+		fi, err := os.Stat(src)
+		if err != nil {
+			res.addErr("move", src, err)
+			continue
+		}
+		if !fi.IsDir() {
+			if skip, err := skipExisting(target, fi, o.overwrite); err != nil || skip {
+				if err != nil {
+					res.addErr("move", src, err)
+				} else {
+					res.add(FileResult{Op: "skip", Src: src, Dst: target})
+				}
+				continue
+			}
+		}
+		if err := os.Rename(src, target); err == nil {
+			res.add(FileResult{Op: "move", Src: src, Dst: target, Bytes: treeSize(target)})
+			continue
+		} else if !crossDevice(err) {
+			res.addErr("move", src, err)
+			continue
+		}
+		// Across devices, fall back to copy and delete.
+		sub := &Result{}
+		copyTree(ctx, sub, src, target, o)
+		for _, fr := range sub.Files {
+			if fr.Op == "copy" {
+				fr.Op = "move"
+			}
+			res.add(fr)
+		}
+		if sub.Errors == 0 && ctx.Err() == nil {
+			if err := os.RemoveAll(src); err != nil {
+				res.addErr("delete", src, err)
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+	return finish(res)
+}
+
+func treeSize(path string) int64 {
+	var n int64
+	filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				n += info.Size()
+			}
+		}
+		return nil
+	})
+	return n
+}
+
+// deleteBuiltin: delete [-r] [-f] PATTERN...
+// Without -r directories are not removed. -f ignores patterns which
+// match nothing.
+func deleteBuiltin(ctx context.Context, env Env, args []string) (*Result, error) {
+	flags := newFlagSet("delete", env)
+	recursive := flags.Bool("r", false, "delete directories and their contents")
+	force := flags.Bool("f", false, "ignore patterns which match nothing")
+	if err := flags.Parse(args); err != nil {
+		return usageErr("delete: %v", err)
+	}
+	if flags.NArg() == 0 {
+		return usageErr("delete: usage: delete [-r] [-f] PATTERN...")
+	}
+	res := &Result{}
+	var paths []string
+	if *force {
+		for _, p := range flags.Args() {
+			m, err := filepath.Glob(resolve(env, p))
+			if err != nil {
+				res.addErr("delete", p, err)
+			}
+			paths = append(paths, m...)
+		}
+	} else {
+		paths = expand(env, res, "delete", flags.Args())
+	}
+	for _, p := range paths {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		fi, err := os.Lstat(p)
+		if err != nil {
+			res.addErr("delete", p, err)
+			continue
+		}
+		if fi.IsDir() {
+			if !*recursive {
+				res.addErr("delete", p, errors.New("is a directory (use -r)"))
+				continue
+			}
+			size := treeSize(p)
+			if err := os.RemoveAll(p); err != nil {
+				res.addErr("delete", p, err)
+				continue
+			}
+			res.add(FileResult{Op: "delete", Src: p, Bytes: size})
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			res.addErr("delete", p, err)
+			continue
+		}
+		res.add(FileResult{Op: "delete", Src: p, Bytes: fi.Size()})
+	}
+	return finish(res)
+}
+
+// mkdirBuiltin: mkdir DIR...
+// Parent directories are created as needed and existing directories
+// are not an error.
+func mkdirBuiltin(ctx context.Context, env Env, args []string) (*Result, error) {
+	flags := newFlagSet("mkdir", env)
+	if err := flags.Parse(args); err != nil {
+		return usageErr("mkdir: %v", err)
+	}
+	if flags.NArg() == 0 {
+		return usageErr("mkdir: usage: mkdir DIR...")
+	}
+	res := &Result{}
+	for _, d := range flags.Args() {
+		p := resolve(env, d)
+		if err := os.MkdirAll(p, 0755); err != nil {
+			res.addErr("mkdir", p, err)
+			continue
+		}
+		res.add(FileResult{Op: "mkdir", Dst: p})
+	}
+	return finish(res)
+}
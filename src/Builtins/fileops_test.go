@@ -0,0 +1,132 @@
+package Builtins
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string, mtime time.Time) {
+	t.Helper()
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, []byte(content), 0640); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, mtime, mtime)
+}
+
+func run(t *testing.T, dir string, argv ...string) *Result {
+	t.Helper()
+	res, err := Run(context.Background(), Env{Dir: dir, Log: io.Discard}, argv)
+	if err != nil {
+		t.Fatalf("%v: %v", argv, err)
+	}
+	return res
+}
+
+func TestCopyGlobRecursivePreserve(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	writeFile(t, filepath.Join(dir, "src", "a.json"), "aa", old)
+	writeFile(t, filepath.Join(dir, "src", "b.less"), "bbb", old)
+	writeFile(t, filepath.Join(dir, "src", "sub", "c.json"), "c", old)
+
+	res := run(t, dir, "copy", "-r", "--preserve", "src/*", "dst/")
+	if res.ExitCode != ExitOK || res.Count != 3 || res.Bytes != 6 {
+		t.Fatalf("Unexpected result: %s", res.Summary())
+	}
+	fi, err := os.Stat(filepath.Join(dir, "dst", "sub", "c.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(old) || fi.Mode().Perm() != 0640 {
+		t.Errorf("Times or permissions not preserved: %v %v", fi.ModTime(), fi.Mode())
+	}
+}
+
+func TestCopySymlinks(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeFile(t, filepath.Join(dir, "lib", "a.txt"), "aa", now)
+	writeFile(t, filepath.Join(dir, "src", "b.txt"), "bbb", now)
+	if err := os.Symlink(filepath.Join(dir, "lib", "a.txt"), filepath.Join(dir, "src", "a.txt")); err != nil {
+		t.Skip(err)
+	}
+	os.Symlink(filepath.Join(dir, "lib"), filepath.Join(dir, "src", "lib"))
+	os.Symlink("..", filepath.Join(dir, "src", "up"))
+
+	t.Log("Given links to a file, a directory and a directory above them:")
+	{
+		res := run(t, dir, "copy", "-r", "src", "dst")
+		if res.ExitCode != ExitFileError || res.Count != 3 || res.Errors != 1 {
+			t.Fatalf("Unexpected result: %s", res.Summary())
+		}
+		for _, name := range []string{"a.txt", filepath.Join("lib", "a.txt")} {
+			fi, err := os.Lstat(filepath.Join(dir, "dst", name))
+			if err != nil || !fi.Mode().IsRegular() || fi.Mode().Perm() != 0640 {
+				t.Errorf("%s: %v %v", name, fi, err)
+			}
+		}
+		if _, err := os.Lstat(filepath.Join(dir, "dst", "up")); err == nil {
+			t.Error("Link to a directory above it copied")
+		}
+	}
+}
+
+func TestCopyOverwritePolicies(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeFile(t, filepath.Join(dir, "a.txt"), "new", now)
+	writeFile(t, filepath.Join(dir, "b.txt"), "old", now.Add(-time.Hour))
+
+	res := run(t, dir, "copy", "--overwrite=never", "a.txt", "b.txt")
+	if res.Skipped != 1 || res.Count != 0 {
+		t.Errorf("never: %s", res.Summary())
+	}
+	res = run(t, dir, "copy", "--overwrite=newer", "--preserve", "a.txt", "b.txt")
+	if res.Count != 1 {
+		t.Errorf("newer: %s", res.Summary())
+	}
+	res = run(t, dir, "copy", "--overwrite=newer", "b.txt", "a.txt")
+	if res.Skipped != 1 {
+		t.Errorf("newer, older source: %s", res.Summary())
+	}
+	if _, err := Run(context.Background(), Env{Dir: dir, Log: io.Discard},
+		[]string{"copy", "--overwrite=sometimes", "a.txt", "b.txt"}); err == nil {
+		t.Error("Expected usage error for bad policy")
+	}
+}
+
+func TestMoveDeleteMkdir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "a", time.Now())
+	writeFile(t, filepath.Join(dir, "tree", "x", "y.txt"), "y", time.Now())
+
+	if res := run(t, dir, "mkdir", "out/deep"); res.ExitCode != ExitOK {
+		t.Errorf("mkdir: %s", res.Summary())
+	}
+	if res := run(t, dir, "move", "a.txt", "out/deep"); res.Count != 1 {
+		t.Errorf("move: %s", res.Summary())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out", "deep", "a.txt")); err != nil {
+		t.Errorf("move target missing: %v", err)
+	}
+	if res := run(t, dir, "move", "tree", "tree/x"); res.ExitCode != ExitFileError || res.Count != 0 {
+		t.Errorf("move into itself must fail: %s", res.Summary())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tree", "x", "y.txt")); err != nil {
+		t.Errorf("failed move changed its source: %v", err)
+	}
+	if res := run(t, dir, "delete", "tree"); res.ExitCode != ExitFileError {
+		t.Errorf("delete of directory without -r must fail: %s", res.Summary())
+	}
+	if res := run(t, dir, "delete", "-r", "tree", "missing*"); res.Count != 1 || res.Errors != 1 {
+		t.Errorf("delete -r: %s", res.Summary())
+	}
+	if res := run(t, dir, "delete", "-f", "missing*"); res.ExitCode != ExitOK {
+		t.Errorf("delete -f: %s", res.Summary())
+	}
+}
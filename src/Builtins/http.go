@@ -0,0 +1,299 @@
+package Builtins
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register("http", httpBuiltin)
+	Register("wait-http", waitHTTPBuiltin)
+}
+
+// statusSet is a list of accepted HTTP status codes, written as
+// "200,204", ranges "200-299" or classes "2xx".
+type statusSet [][2]int
+
+func parseStatusSet(s string) (statusSet, error) {
+	var set statusSet
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(strings.ToLower(part))
+		lo, hi, isRange := strings.Cut(part, "-")
+		if len(part) == 3 && strings.HasSuffix(part, "xx") {
+			lo, hi, isRange = part[:1]+"00", part[:1]+"99", true
+		}
+		if !isRange {
+			hi = lo
+		}
+		a, errA := strconv.Atoi(lo)
+		b, errB := strconv.Atoi(hi)
+		if errA != nil || errB != nil || a < 100 || b > 599 || a > b {
+			return nil, fmt.Errorf("invalid status %q", part)
+		}
+		set = append(set, [2]int{a, b})
+	}
+	return set, nil
+}
+
+func (s statusSet) has(code int) bool {
+	for _, r := range s {
+		if code >= r[0] && code <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// headerList is a repeatable "Name: value" flag.
+type headerList []string
+
+func (h *headerList) String() string { return strings.Join(*h, ", ") }
+
+func (h *headerList) Set(v string) error {
+	if name, _, ok := strings.Cut(v, ":"); !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("header %q is not \"Name: value\"", v)
+	}
+	*h = append(*h, v)
+	return nil
+}
+
+func newClient(maxRedirects int) *http.Client {
+	return &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+}
+
+type httpOpts struct {
+	method    string
+	output    string
+	sha256    string
+	data      string
+	headers   headerList
+	accept    statusSet
+	timeout   time.Duration
+	redirects int
+	retries   int
+	retryWait time.Duration
+}
+
+// httpBuiltin: http [--method GET] [-H "Name: value"]... [--data BODY]
+//
+//	[-o FILE] [--sha256 HEX] [--status 2xx] [--max-redirects 10]
+//	[--timeout 1m] [--retries 3] [--retry-wait 2s] URL
+//
+// Sends one request, retrying on network errors and 5xx or 429
+// responses. With -o the body is written to a temporary file and
+// renamed into place only when the status is accepted and, with
+// --sha256, the body matches the pinned digest.
+func httpBuiltin(ctx context.Context, env Env, args []string) (*Result, error) {
+	o := &httpOpts{}
+	var status string
+	flags := newFlagSet("http", env)
+	flags.StringVar(&o.method, "method", http.MethodGet, "request method")
+	flags.Var(&o.headers, "H", "request header, \"Name: value\"")
+	flags.StringVar(&o.data, "data", "", "request body")
+	flags.StringVar(&o.output, "o", "", "save the response body to this file")
+	flags.StringVar(&o.sha256, "sha256", "", "expected SHA-256 of the response body")
+	flags.StringVar(&status, "status", "2xx", "accepted status codes, e.g. 200,204 or 2xx")
+	flags.IntVar(&o.redirects, "max-redirects", 10, "redirects to follow; 0 returns the redirect itself")
+	flags.DurationVar(&o.timeout, "timeout", time.Minute, "timeout of each attempt")
+	flags.IntVar(&o.retries, "retries", 3, "retries after a network error or a 5xx or 429 response")
+	flags.DurationVar(&o.retryWait, "retry-wait", 2*time.Second, "wait between retries")
+	if err := flags.Parse(args); err != nil {
+		return usageErr("http: %v", err)
+	}
+	if flags.NArg() != 1 {
+		return usageErr("http: usage: http [options] URL")
+	}
+	var err error
+	if o.accept, err = parseStatusSet(status); err != nil {
+		return usageErr("http: --status: %v", err)
+	}
+	if o.sha256 != "" {
+		if b, err := hex.DecodeString(o.sha256); err != nil || len(b) != sha256.Size {
+			return usageErr("http: --sha256 %q is not a SHA-256 digest", o.sha256)
+		}
+		o.sha256 = strings.ToLower(o.sha256)
+	}
+	url := flags.Arg(0)
+	if _, err := http.NewRequest(o.method, url, nil); err != nil {
+		return usageErr("http: %v", err)
+	}
+	if o.output != "" {
+		o.output = resolve(env, o.output)
+	}
+
+	res := &Result{}
+	client := newClient(o.redirects)
+	for attempt := 0; ; attempt++ {
+		fr, retry := o.attempt(ctx, env, client, url)
+		if fr.Err == "" || !retry || attempt >= o.retries || ctx.Err() != nil {
+			res.add(fr)
+			break
+		}
+		fmt.Fprintf(env.Log, "    %s %s: %s; retrying in %v\n", o.method, url, fr.Err, o.retryWait)
+		select {
+		case <-ctx.Done():
+		case <-time.After(o.retryWait):
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+	return finish(res)
+}
+
+// attempt sends the request once. retry reports whether a failure
+// may be transient.
+func (o *httpOpts) attempt(ctx context.Context, env Env, client *http.Client, url string) (fr FileResult, retry bool) {
+	fr = FileResult{Op: "http", Src: url, Dst: o.output}
+	actx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+	var body io.Reader
+	if o.data != "" {
+		body = strings.NewReader(o.data)
+	}
+	req, err := http.NewRequestWithContext(actx, o.method, url, body)
+	if err != nil {
+		fr.Err = err.Error()
+		return fr, false
+	}
+	for _, h := range o.headers {
+		name, value, _ := strings.Cut(h, ":")
+		req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		fr.Err = err.Error()
+		return fr, true
+	}
+	defer resp.Body.Close()
+	fmt.Fprintf(env.Log, "    %s %s: %s\n", o.method, url, resp.Status)
+	if !o.accept.has(resp.StatusCode) {
+		io.Copy(io.Discard, resp.Body)
+		fr.Err = "unexpected status " + resp.Status
+		return fr, resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	}
+
+	var out io.Writer = io.Discard
+	var tmp *os.File
+	if o.output != "" {
+		if err := os.MkdirAll(filepath.Dir(o.output), 0755); err != nil {
+			fr.Err = err.Error()
+			return fr, false
+		}
+		if tmp, err = os.CreateTemp(filepath.Dir(o.output), "."+filepath.Base(o.output)+".*"); err != nil {
+			fr.Err = err.Error()
+			return fr, false
+		}
+		defer os.Remove(tmp.Name())
+		out = tmp
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, h), resp.Body)
+	if tmp != nil {
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fr.Err = err.Error()
+		return fr, true
+	}
+	fr.Bytes = n
+	digest := hex.EncodeToString(h.Sum(nil))
+	fmt.Fprintf(env.Log, "    %s  %d bytes\n", digest, n)
+	if o.sha256 != "" && digest != o.sha256 {
+		fr.Err = fmt.Sprintf("SHA-256 mismatch: got %s, want %s", digest, o.sha256)
+		return fr, false
+	}
+	if tmp != nil {
+		err := os.Chmod(tmp.Name(), 0644)
+		if err == nil {
+			err = os.Rename(tmp.Name(), o.output)
+		}
+		if err != nil {
+			fr.Err = err.Error()
+		}
+	}
+	return fr, false
+}
+
+// waitHTTPBuiltin: wait-http [--status 200] [--timeout 5m]
+//
+//	[--interval 2s] [--request-timeout 10s] URL
+//
+// Polls URL with GET until it answers with an accepted status. The
+// job fails when --timeout expires first.
+func waitHTTPBuiltin(ctx context.Context, env Env, args []string) (*Result, error) {
+	flags := newFlagSet("wait-http", env)
+	status := flags.String("status", "200", "accepted status codes, e.g. 200,204 or 2xx")
+	timeout := flags.Duration("timeout", 5*time.Minute, "give up after this long")
+	interval := flags.Duration("interval", 2*time.Second, "wait between polls")
+	reqTimeout := flags.Duration("request-timeout", 10*time.Second, "timeout of each poll")
+	if err := flags.Parse(args); err != nil {
+		return usageErr("wait-http: %v", err)
+	}
+	if flags.NArg() != 1 {
+		return usageErr("wait-http: usage: wait-http [options] URL")
+	}
+	accept, err := parseStatusSet(*status)
+	if err != nil {
+		return usageErr("wait-http: --status: %v", err)
+	}
+	url := flags.Arg(0)
+	if _, err := http.NewRequest(http.MethodGet, url, nil); err != nil {
+		return usageErr("wait-http: %v", err)
+	}
+
+	res := &Result{}
+	client := newClient(10)
+	deadline := time.Now().Add(*timeout)
+	var last error
+	for polls := 1; ; polls++ {
+		pctx, cancel := context.WithTimeout(ctx, *reqTimeout)
+		req, _ := http.NewRequestWithContext(pctx, http.MethodGet, url, nil)
+		resp, err := client.Do(req)
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			if accept.has(resp.StatusCode) {
+				cancel()
+				fmt.Fprintf(env.Log, "    %s: %s after %d poll(s)\n", url, resp.Status, polls)
+				res.add(FileResult{Op: "wait-http", Src: url})
+				return finish(res)
+			}
+			err = errors.New(resp.Status)
+		}
+		cancel()
+		last = err
+		if ctx.Err() != nil {
+			return res, ctx.Err()
+		}
+		if !time.Now().Add(*interval).Before(deadline) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return res, ctx.Err()
+		case <-time.After(*interval):
+		}
+	}
+	res.addErr("wait-http", url, fmt.Errorf("not ready after %v: %v", *timeout, last))
+	return finish(res)
+}
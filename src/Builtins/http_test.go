@@ -0,0 +1,124 @@
+package Builtins
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHTTPDownload(t *testing.T) {
+	payload := "tool binary"
+	sum := sha256.Sum256([]byte(payload))
+	digest := hex.EncodeToString(sum[:])
+	var flaky int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tool", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(payload)) })
+	mux.Handle("/moved", http.RedirectHandler("/tool", http.StatusFound))
+	mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&flaky, 1) < 3 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(payload))
+	})
+	mux.HandleFunc("/created", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("X-Token") != "t" {
+			http.Error(w, "bad", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	dir := t.TempDir()
+
+	t.Log("Given a redirect to a pinned download:")
+	{
+		res := run(t, dir, "http", "-o", "bin/tool", "--sha256", digest, srv.URL+"/moved")
+		if res.ExitCode != ExitOK || res.Bytes != int64(len(payload)) {
+			t.Fatalf("download: %s", res.Summary())
+		}
+		if data, _ := os.ReadFile(filepath.Join(dir, "bin", "tool")); string(data) != payload {
+			t.Errorf("Saved %q", data)
+		}
+	}
+
+	t.Log("Given a digest mismatch:")
+	{
+		res := run(t, dir, "http", "-o", "bad", "--sha256", digest[1:]+"0", srv.URL+"/tool")
+		if res.ExitCode != ExitFileError {
+			t.Errorf("mismatch: %s", res.Summary())
+		}
+		if _, err := os.Stat(filepath.Join(dir, "bad")); err == nil {
+			t.Error("File saved despite digest mismatch")
+		}
+	}
+
+	t.Log("Given redirects disabled:")
+	{
+		if res := run(t, dir, "http", "--max-redirects", "0", srv.URL+"/moved"); res.ExitCode != ExitFileError {
+			t.Errorf("redirect: %s", res.Summary())
+		}
+		if res := run(t, dir, "http", "--max-redirects", "0", "--status", "302", srv.URL+"/moved"); res.ExitCode != ExitOK {
+			t.Errorf("accepted redirect: %s", res.Summary())
+		}
+	}
+
+	t.Log("Given transient 503 responses:")
+	{
+		if res := run(t, dir, "http", "--retry-wait", "1ms", srv.URL+"/flaky"); res.ExitCode != ExitOK {
+			t.Errorf("retry: %s", res.Summary())
+		}
+		if res := run(t, dir, "http", "--retries", "0", srv.URL+"/missing"); res.ExitCode != ExitFileError {
+			t.Errorf("404: %s", res.Summary())
+		}
+	}
+
+	t.Log("Given a POST with a header:")
+	{
+		res := run(t, dir, "http", "--method", "POST", "-H", "X-Token: t", "--data", "{}", "--status", "201", srv.URL+"/created")
+		if res.ExitCode != ExitOK {
+			t.Errorf("post: %s", res.Summary())
+		}
+	}
+}
+
+func TestWaitHTTP(t *testing.T) {
+	var polls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&polls, 1) < 3 {
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+
+	if res := run(t, dir, "wait-http", "--interval", "5ms", "--timeout", "5s", srv.URL); res.ExitCode != ExitOK {
+		t.Errorf("wait-http: %s", res.Summary())
+	}
+	if res := run(t, dir, "wait-http", "--interval", "5ms", "--timeout", "30ms", "--status", "204", srv.URL); res.ExitCode != ExitFileError {
+		t.Errorf("wait-http timeout: %s", res.Summary())
+	}
+}
+
+func TestParseStatusSet(t *testing.T) {
+	set, err := parseStatusSet("200,204, 3xx,401-403")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for code, want := range map[int]bool{200: true, 201: false, 302: true, 402: true, 404: false} {
+		if set.has(code) != want {
+			t.Errorf("%d: got %v", code, !want)
+		}
+	}
+	for _, bad := range []string{"", "abc", "600", "300-200"} {
+		if _, err := parseStatusSet(bad); err == nil {
+			t.Errorf("%q was accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package Builtins
+
+import (
+	"errors"
+	"syscall"
+)
+
+// crossDevice reports whether err is that of a rename between file
+// systems.
+func crossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
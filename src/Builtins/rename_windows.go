@@ -0,0 +1,16 @@
+package Builtins
+
+import (
+	"errors"
+	"syscall"
+)
+
+// errNotSameDevice is ERROR_NOT_SAME_DEVICE, which Windows returns
+// for a rename between volumes.
+const errNotSameDevice = syscall.Errno(17)
+
+// crossDevice reports whether err is that of a rename between file
+// systems.
+func crossDevice(err error) bool {
+	return errors.Is(err, errNotSameDevice) || errors.Is(err, syscall.EXDEV)
+}
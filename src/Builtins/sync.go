@@ -0,0 +1,325 @@
+package Builtins
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Exit code bits of the sync builtin. They follow robocopy, so that
+// kill_jobs_on_exit_code_greater_than thresholds written for
+// robocopy (typically 7 or 15) keep working.
+const (
+	SyncCopied     = 1  // one or more files were copied
+	SyncExtras     = 2  // extra files or directories found in the destination
+	SyncMismatched = 4  // a file and a directory share a name
+	SyncFailed     = 8  // some files could not be copied
+	SyncFatal      = 16 // bad arguments or unreadable source
+)
+
+// Comparison modes deciding whether a destination file is current.
+const (
+	CompareSize  = "size"
+	CompareMtime = "mtime"
+	CompareHash  = "hash"
+)
+
+func init() {
+	Register("sync", syncBuiltin)
+	successMax["sync"] = SyncCopied | SyncExtras | SyncMismatched
+}
+
+// patternList is a repeatable flag. Each value may hold several
+// space separated patterns, as in "*.json *.cson *.less".
+type patternList []string
+
+func (p *patternList) String() string { return strings.Join(*p, " ") }
+
+func (p *patternList) Set(v string) error {
+	*p = append(*p, strings.Fields(v)...)
+	return nil
+}
+
+type syncOpts struct {
+	include   patternList
+	exclude   patternList
+	mirror    bool
+	compare   string
+	retries   int
+	retryWait time.Duration
+	dryRun    bool
+}
+
+// included applies the filters to a path relative to the source
+// root. Excludes match the base name or the relative path; includes
+// apply to files only and match the base name.
+func (o *syncOpts) included(rel string, isDir bool) bool {
+	base := filepath.Base(rel)
+	for _, x := range o.exclude {
+		if m, _ := filepath.Match(x, base); m {
+			return false
+		}
+		if m, _ := filepath.Match(x, filepath.ToSlash(rel)); m {
+			return false
+		}
+	}
+	if isDir || len(o.include) == 0 {
+		return true
+	}
+	for _, in := range o.include {
+		if m, _ := filepath.Match(in, base); m {
+			return true
+		}
+	}
+	return false
+}
+
+// syncBuiltin: sync [--include PATTERNS]... [--exclude PATTERNS]...
+//
+//	[--mirror] [--compare=size|mtime|hash] [--retries N]
+//	[--retry-wait DURATION] [--dry-run] SRC DST
+//
+// Copies the SRC tree to DST, replacing files which differ. Mirror
+// mode also purges destination entries missing from the source.
+// File and directory times are preserved. The exit code is a
+// combination of the Sync bits.
+func syncBuiltin(ctx context.Context, env Env, args []string) (*Result, error) {
+	o := &syncOpts{}
+	flags := newFlagSet("sync", env)
+	flags.Var(&o.include, "include", "copy only files matching these patterns")
+	flags.Var(&o.exclude, "exclude", "skip files and directories matching these patterns")
+	flags.BoolVar(&o.mirror, "mirror", false, "purge destination entries which are not in the source")
+	flags.StringVar(&o.compare, "compare", CompareMtime, "size, mtime (size and time) or hash")
+	flags.IntVar(&o.retries, "retries", 3, "retries for a file which cannot be copied")
+	flags.DurationVar(&o.retryWait, "retry-wait", 2*time.Second, "wait between retries")
+	flags.BoolVar(&o.dryRun, "dry-run", false, "list what would change without changing anything")
+	if err := flags.Parse(args); err != nil {
+		return syncFatal("sync: %v", err)
+	}
+	switch o.compare {
+	case CompareSize, CompareMtime, CompareHash:
+	default:
+		return syncFatal("sync: invalid --compare %q: use size, mtime or hash", o.compare)
+	}
+	if flags.NArg() != 2 {
+		return syncFatal("sync: usage: sync [options] SRC DST")
+	}
+	src, dst := resolve(env, flags.Arg(0)), resolve(env, flags.Arg(1))
+	if fi, err := os.Stat(src); err != nil || !fi.IsDir() {
+		return syncFatal("sync: source %s is not a directory", src)
+	}
+
+	s := &syncer{o: o, src: src, dst: dst, res: &Result{}, seen: map[string]bool{}}
+	err := s.copyTree(ctx)
+	if err == nil {
+		err = s.findExtras(ctx)
+	}
+	if err == nil && !o.dryRun {
+		s.restoreDirTimes()
+	}
+	s.res.ExitCode = s.bits
+	if s.res.Errors > 0 {
+		s.res.ExitCode |= SyncFailed
+	}
+	return s.res, err
+}
+
+func syncFatal(format string, a ...interface{}) (*Result, error) {
+	return &Result{ExitCode: SyncFatal}, fmt.Errorf(format, a...)
+}
+
+type syncer struct {
+	o        *syncOpts
+	src, dst string
+	res      *Result
+	bits     int
+	// seen holds the relative paths present in the source.
+	seen map[string]bool
+	dirs []string
+}
+
+func (s *syncer) op(name string) string {
+	if s.o.dryRun {
+		return "would " + name
+	}
+	return name
+}
+
+func (s *syncer) copyTree(ctx context.Context) error {
+	return filepath.WalkDir(s.src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			s.res.addErr("sync", path, err)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		rel, _ := filepath.Rel(s.src, path)
+		if rel != "." && !s.o.included(rel, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		s.seen[rel] = true
+		target := filepath.Join(s.dst, rel)
+		di, derr := os.Stat(target)
+		if d.IsDir() {
+			s.dirs = append(s.dirs, rel)
+			if derr == nil && !di.IsDir() {
+				s.bits |= SyncMismatched
+				s.res.add(FileResult{Op: "mismatch", Src: path, Dst: target})
+				return fs.SkipDir
+			}
+			if derr != nil && !s.o.dryRun {
+				if err := os.MkdirAll(target, 0755); err != nil {
+					s.res.addErr("mkdir", target, err)
+					return fs.SkipDir
+				}
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			s.res.addErr("sync", path, err)
+			return nil
+		}
+		if derr == nil && di.IsDir() {
+			s.bits |= SyncMismatched
+			s.res.add(FileResult{Op: "mismatch", Src: path, Dst: target})
+			return nil
+		}
+		if derr == nil && s.same(path, info, target, di) {
+			s.res.add(FileResult{Op: "skip", Src: path, Dst: target})
+			return nil
+		}
+		fr := FileResult{Op: s.op("copy"), Src: path, Dst: target, Bytes: info.Size()}
+		if !s.o.dryRun {
+			if err := s.copyWithRetry(ctx, path, target, info); err != nil {
+				fr.Err, fr.Bytes = err.Error(), 0
+			}
+		}
+		if fr.Err == "" {
+			s.bits |= SyncCopied
+		}
+		s.res.add(fr)
+		return nil
+	})
+}
+
+// same reports whether the destination file is current.
+func (s *syncer) same(src string, si os.FileInfo, dst string, di os.FileInfo) bool {
+	if si.Size() != di.Size() {
+		return false
+	}
+	switch s.o.compare {
+	case CompareMtime:
+		// Some file systems keep times at 2 second resolution.
+		diff := si.ModTime().Sub(di.ModTime())
+		return diff < 2*time.Second && diff > -2*time.Second
+	case CompareHash:
+		a, errA := fileHash(src)
+		b, errB := fileHash(dst)
+		return errA == nil && errB == nil && bytes.Equal(a, b)
+	}
+	return true
+}
+
+func fileHash(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// copyWithRetry copies a file, retrying when it cannot be opened or
+// replaced, e.g. because another process holds it locked.
+func (s *syncer) copyWithRetry(ctx context.Context, src, dst string, info os.FileInfo) error {
+	var err error
+	for attempt := 0; attempt <= s.o.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(s.o.retryWait):
+			}
+		}
+		if _, err = copyContent(src, dst, info.Mode().Perm()); err == nil {
+			return os.Chtimes(dst, info.ModTime(), info.ModTime())
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return fmt.Errorf("%v (after %d retries)", err, s.o.retries)
+}
+
+// findExtras reports, and in mirror mode purges, destination entries
+// which are not in the source. Excluded entries are left alone.
+func (s *syncer) findExtras(ctx context.Context) error {
+	if _, err := os.Stat(s.dst); err != nil {
+		return nil
+	}
+	return filepath.WalkDir(s.dst, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		rel, _ := filepath.Rel(s.dst, path)
+		if rel == "." || s.seen[rel] {
+			return nil
+		}
+		if !s.o.included(rel, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		s.bits |= SyncExtras
+		if !s.o.mirror {
+			s.res.add(FileResult{Op: "extra", Dst: path})
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		fr := FileResult{Op: s.op("purge"), Dst: path, Bytes: treeSize(path)}
+		if !s.o.dryRun {
+			if err := os.RemoveAll(path); err != nil {
+				fr.Err = err.Error()
+			}
+		}
+		s.res.add(fr)
+		if d.IsDir() {
+			return fs.SkipDir
+		}
+		return nil
+	})
+}
+
+// restoreDirTimes copies directory times, deepest first, once all
+// files are in place.
+func (s *syncer) restoreDirTimes() {
+	for i := len(s.dirs) - 1; i >= 0; i-- {
+		rel := s.dirs[i]
+		if fi, err := os.Stat(filepath.Join(s.src, rel)); err == nil {
+			os.Chtimes(filepath.Join(s.dst, rel), fi.ModTime(), fi.ModTime())
+		}
+	}
+}
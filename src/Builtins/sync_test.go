@@ -0,0 +1,72 @@
+package Builtins
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSyncFiltersAndMirror(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeFile(t, filepath.Join(dir, "src", "a.json"), "a", now)
+	writeFile(t, filepath.Join(dir, "src", "b.cson"), "b", now)
+	writeFile(t, filepath.Join(dir, "src", "c.exe"), "c", now)
+	writeFile(t, filepath.Join(dir, "src", "node_modules", "d.json"), "d", now)
+	writeFile(t, filepath.Join(dir, "dst", "stale.json"), "s", now)
+	writeFile(t, filepath.Join(dir, "dst", "keep.exe"), "k", now)
+
+	args := []string{"sync", "--include", "*.json *.cson", "--exclude", "node_modules", "--retries", "0"}
+
+	t.Log("Given a dry run:")
+	{
+		res := run(t, dir, append(args, "--mirror", "--dry-run", "src", "dst")...)
+		if res.ExitCode != SyncCopied|SyncExtras {
+			t.Errorf("Expected exit code 3, got %s", res.Summary())
+		}
+		if _, err := os.Stat(filepath.Join(dir, "dst", "a.json")); err == nil {
+			t.Error("Dry run copied a file")
+		}
+	}
+	t.Log("Given a copy without mirror:")
+	{
+		res := run(t, dir, append(args, "src", "dst")...)
+		if res.ExitCode != SyncCopied|SyncExtras || res.Count != 2 {
+			t.Errorf("Expected 2 files copied and extras, got %s", res.Summary())
+		}
+		fi, err := os.Stat(filepath.Join(dir, "dst", "a.json"))
+		if err != nil || !fi.ModTime().Equal(now) {
+			t.Errorf("Copied file missing or time not preserved: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "dst", "node_modules")); err == nil {
+			t.Error("Excluded directory was copied")
+		}
+	}
+	t.Log("Given a mirror of an unchanged tree:")
+	{
+		res := run(t, dir, append(args, "--mirror", "src", "dst")...)
+		if res.ExitCode != SyncExtras || res.Count != 1 {
+			t.Errorf("Expected only a purge, got %s", res.Summary())
+		}
+		if _, err := os.Stat(filepath.Join(dir, "dst", "stale.json")); err == nil {
+			t.Error("Extra file was not purged")
+		}
+		if _, err := os.Stat(filepath.Join(dir, "dst", "keep.exe")); err != nil {
+			t.Error("File outside the filter was purged")
+		}
+		res = run(t, dir, append(args, "--mirror", "src", "dst")...)
+		if res.ExitCode != 0 {
+			t.Errorf("Expected no changes, got %s", res.Summary())
+		}
+	}
+}
+
+func TestSyncUsageIsFatal(t *testing.T) {
+	res, err := Run(context.Background(), Env{Log: io.Discard}, []string{"sync", "--compare=color", "a", "b"})
+	if err == nil || res.ExitCode != SyncFatal {
+		t.Errorf("Expected fatal exit code, got %d, %v", res.ExitCode, err)
+	}
+}
@@ -0,0 +1,49 @@
+package CmdRunner
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// lockedWriter serialises the writes of several goroutines, such as
+// a job's output copier and the runner's progress messages, to one
+// writer.
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// activityWriter forwards a job's stdout and stderr to the log and
+// remembers when the job last wrote anything.
+type activityWriter struct {
+	w    io.Writer
+	last atomic.Int64
+}
+
+func newActivityWriter(w io.Writer) *activityWriter {
+	a := &activityWriter{w: w}
+	a.touch()
+	return a
+}
+
+func (a *activityWriter) Write(p []byte) (int, error) {
+	a.touch()
+	return a.w.Write(p)
+}
+
+func (a *activityWriter) touch() {
+	a.last.Store(time.Now().UnixNano())
+}
+
+// idleFor returns the time elapsed since the last write.
+func (a *activityWriter) idleFor() time.Duration {
+	return time.Since(time.Unix(0, a.last.Load()))
+}
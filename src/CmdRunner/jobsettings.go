@@ -0,0 +1,17 @@
+package CmdRunner
+
+import (
+	"time"
+
+	ds "go_cmdrX/src/DataStrucs"
+)
+
+// JobStartTime returns the earliest time a job may be launched,
+// given the time the runner reached it. start_cmd_date_time takes
+// precedence over delay_cmd_start_seconds.
+func JobStartTime(job ds.CmdJob, reached time.Time) time.Time {
+	if !job.StartAtDateTime.IsZero() {
+		return job.StartAtDateTime.Time
+	}
+	return reached.Add(job.DelayStartSecs.Duration)
+}
@@ -0,0 +1,140 @@
+package CmdRunner
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	ds "go_cmdrX/src/DataStrucs"
+	eu "go_cmdrX/src/ErrUtil"
+)
+
+// ExitCodePolicy holds a job's parsed kill_jobs_on_exit_code
+// thresholds. A nil threshold is not checked.
+type ExitCodePolicy struct {
+	KillGreaterThan *int `json:"kill_jobs_on_exit_code_greater_than"`
+	KillLessThan    *int `json:"kill_jobs_on_exit_code_less_than"`
+}
+
+// Check returns an error if exitCode lies outside the thresholds.
+func (p ExitCodePolicy) Check(exitCode int) error {
+	if p.KillGreaterThan != nil && exitCode > *p.KillGreaterThan {
+		return fmt.Errorf("exit code %d is greater than %d", exitCode, *p.KillGreaterThan)
+	}
+	if p.KillLessThan != nil && exitCode < *p.KillLessThan {
+		return fmt.Errorf("exit code %d is less than %d", exitCode, *p.KillLessThan)
+	}
+	return nil
+}
+
+func (p ExitCodePolicy) String() string {
+	var parts []string
+	if p.KillGreaterThan != nil {
+		parts = append(parts, fmt.Sprintf("fail if > %d", *p.KillGreaterThan))
+	}
+	if p.KillLessThan != nil {
+		parts = append(parts, fmt.Sprintf("fail if < %d", *p.KillLessThan))
+	}
+	if len(parts) == 0 {
+		return "any exit code accepted"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// JobPlan is a job with every setting resolved: exactly what the
+// runner will launch, where, when and under which limits.
+type JobPlan struct {
+	Index       int            `json:"index"`
+	DisplayName string         `json:"cmd_display_name"`
+	Type        string         `json:"cmd_type"`
+	Argv        []string       `json:"argv"`
+	Dir         string         `json:"dir"`
+	Env         []string       `json:"env"`
+	LogPath     string         `json:"log_path"`
+	StartAt     time.Time      `json:"start_at"`
+	TimeOut     time.Duration  `json:"-"`
+	IdleTimeOut time.Duration  `json:"-"`
+	ExitCodes   ExitCodePolicy `json:"exit_code_policy"`
+	// String forms of TimeOut and IdleTimeOut for JSON output.
+	TimeOutStr     string `json:"timeout"`
+	IdleTimeOutStr string `json:"idle_timeout"`
+}
+
+// EnvDiff returns the environment entries the job sets on top of
+// the runner's environment, formatted as "+NAME=value".
+func (p JobPlan) EnvDiff() []string {
+	var diff []string
+	for _, kv := range p.Env {
+		diff = append(diff, "+"+kv)
+	}
+	return diff
+}
+
+// ResolveJob resolves a job's settings. reached is the time the
+// runner arrives at the job, used to compute its start time.
+func (r *Runner) ResolveJob(idx int, job ds.CmdJob, reached time.Time) (JobPlan, error) {
+	p := JobPlan{Index: idx + 1, DisplayName: job.DisplayName, Type: job.Type, Env: []string{}}
+	var errs []error
+	add := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	p.Argv = JobArgs(job)
+	p.LogPath = job.LogPathFileName
+	dir, err := filepath.Abs(r.JobDir(job))
+	add(err)
+	p.Dir = dir
+
+	p.TimeOut, p.IdleTimeOut = job.TimeOutMinutes.Duration, job.IdleTimeOutMinutes.Duration
+	p.TimeOutStr, p.IdleTimeOutStr = durationStr(p.TimeOut), durationStr(p.IdleTimeOut)
+	p.StartAt = JobStartTime(job, reached)
+	p.ExitCodes.KillGreaterThan = job.KillOnExitCodeGreaterThan.Ptr()
+	p.ExitCodes.KillLessThan = job.KillOnExitCodeLessThan.Ptr()
+
+	for _, name := range job.Secrets {
+		if r.Secrets == nil {
+			add(fmt.Errorf("secret %q: no secrets declared", name))
+			continue
+		}
+		v, err := r.Secrets.Get(name)
+		if err != nil {
+			add(err)
+			continue
+		}
+		p.Env = append(p.Env, name+"="+v)
+	}
+
+	return p, errors.Join(errs...)
+}
+
+// Plan resolves every job in the batch without launching anything.
+// Start times assume each job finishes the moment it starts, so
+// they are the earliest possible times. All validation and
+// resolution problems are returned together.
+func (r *Runner) Plan(now time.Time) ([]JobPlan, error) {
+	var plans []JobPlan
+	errs := []error{r.Validate()}
+	reached := now
+	for i, job := range r.Batch.Batch.Jobs {
+		p, err := r.ResolveJob(i, job, reached)
+		if err != nil {
+			errs = append(errs, eu.JobError(eu.Validation, i+1, job.DisplayName, err))
+		}
+		if p.StartAt.After(reached) {
+			reached = p.StartAt
+		}
+		plans = append(plans, p)
+	}
+	return plans, errors.Join(errs...)
+}
+
+func durationStr(d time.Duration) string {
+	if d == 0 {
+		return "none"
+	}
+	return d.String()
+}
@@ -0,0 +1,105 @@
+package CmdRunner
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	ds "go_cmdrX/src/DataStrucs"
+)
+
+func TestPlanResolvesJobs(t *testing.T) {
+	batch := ds.JsonCmdBatch{}
+	batch.Batch.Hdr.CmdExeDirectory = "/tmp"
+	batch.Batch.Jobs = []ds.CmdJob{
+		{DisplayName: "A", DelayStartSecs: ds.Seconds{Duration: 30 * time.Second},
+			TimeOutMinutes:            ds.Minutes{Duration: 90 * time.Second},
+			KillOnExitCodeGreaterThan: ds.NewOptInt(7),
+			CmdElements:               []ds.CmdElement{{CmdUnit: "echo"}, {CmdUnit: "a b"}}},
+		{DisplayName: "B", DelayStartSecs: ds.Seconds{Duration: 10 * time.Second}, ExeDir: "/var",
+			CmdElements: []ds.CmdElement{{CmdUnit: "true"}}},
+	}
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)
+	r := Runner{Batch: batch}
+	plans, err := r.Plan(now)
+	if err != nil {
+		t.Fatalf("Unexpected plan error: %v", err)
+	}
+	if plans[0].Dir != "/tmp" || plans[1].Dir != "/var" {
+		t.Errorf("Unexpected dirs: %q %q", plans[0].Dir, plans[1].Dir)
+	}
+	if plans[0].TimeOut != 90*time.Second {
+		t.Errorf("Expected 90s time out, got %v", plans[0].TimeOut)
+	}
+	if !plans[1].StartAt.Equal(now.Add(40 * time.Second)) {
+		t.Errorf("Expected cumulative delays, got start %v", plans[1].StartAt)
+	}
+	if plans[0].ExitCodes.Check(8) == nil || plans[0].ExitCodes.Check(7) != nil {
+		t.Errorf("Exit code policy not applied: %v", plans[0].ExitCodes)
+	}
+}
+
+func TestPlanReportsAllErrors(t *testing.T) {
+	var batch ds.JsonCmdBatch
+	err := json.Unmarshal([]byte(`{"commands_batch": {
+  "jobs_header": {"command_exe_directory": "/no/such/dir"},
+  "command_jobs": [
+    {"cmd_display_name": "A", "cmd_timeout_in_minutes": "fifteen", "cmd_idle_timeout_in_minutes": "NaN"},
+    {"cmd_display_name": "B", "kill_jobs_on_exit_code_less_than": "x",
+     "cmd_idle_timeout_in_minutes": -1, "execute_cmd_in_dir": "/tmp", "cmd_timeout_in_minutes": 1e30,
+     "cmd_elements": [{"cmdelement": "true"}]},
+    {"cmd_display_name": "A", "delay_cmd_start_seconds": "-5",
+     "cmd_elements": [{"cmdelement": "true"}]}
+  ]}}`), &batch)
+	if err != nil {
+		t.Fatalf("Legacy string values should decode: %v", err)
+	}
+	r := Runner{Batch: batch}
+	_, err = r.Plan(time.Now())
+	if err == nil {
+		t.Fatal("Expected plan errors")
+	}
+	for _, want := range []string{
+		`command_exe_directory: directory "/no/such/dir" does not exist`,
+		"no command elements",
+		`invalid cmd_timeout_in_minutes: "fifteen" is not a number of minutes`,
+		`invalid kill_jobs_on_exit_code_less_than: "x" is not an integer`,
+		"invalid cmd_idle_timeout_in_minutes: -1m0s is negative",
+		`Job 3 "A": invalid delay_cmd_start_seconds: -5s is negative`,
+		`invalid cmd_timeout_in_minutes: "1e30" is out of range: expected a finite number of at most 153722867 minutes`,
+		`invalid cmd_idle_timeout_in_minutes: "NaN" is out of range`,
+		"cmd_display_name is also used by job 1",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error mentioning %q, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestTypedFieldsAcceptLegacyStrings(t *testing.T) {
+	var job ds.CmdJob
+	err := json.Unmarshal([]byte(`{"cmd_timeout_in_minutes": "15.0", "cmd_idle_timeout_in_minutes": 0.5,
+  "delay_cmd_start_seconds": "1m", "kill_jobs_on_exit_code_greater_than": "",
+  "kill_jobs_on_exit_code_less_than": 0, "start_cmd_date_time": "2026-01-02 03:04:05"}`), &job)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if job.TimeOutMinutes.Duration != 15*time.Minute || job.IdleTimeOutMinutes.Duration != 30*time.Second ||
+		job.DelayStartSecs.Duration != time.Minute {
+		t.Errorf("Durations not parsed: %v %v %v", job.TimeOutMinutes, job.IdleTimeOutMinutes, job.DelayStartSecs)
+	}
+	if job.KillOnExitCodeGreaterThan.Set || job.KillOnExitCodeLessThan != ds.NewOptInt(0) {
+		t.Errorf("Exit code thresholds not parsed: %+v %+v", job.KillOnExitCodeGreaterThan, job.KillOnExitCodeLessThan)
+	}
+	if want := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local); !job.StartAtDateTime.Equal(want) {
+		t.Errorf("Expected start %v, got %v", want, job.StartAtDateTime)
+	}
+	out, _ := json.Marshal(job)
+	for _, want := range []string{`"cmd_timeout_in_minutes":15`, `"kill_jobs_on_exit_code_greater_than":null`,
+		`"start_cmd_date_time":"2026-01-02 03:04:05"`} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("Expected %s in %s", want, out)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package CmdRunner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// setProcAttrs starts the job in its own process group so that the
+// whole tree can be killed on time out.
+func setProcAttrs(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killProcTree(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+		cmd.Process.Kill()
+	}
+}
+
+type procInfo struct {
+	pid   int
+	ppid  int
+	state string
+	comm  string
+}
+
+// readProcStat parses /proc/<pid>/stat. The command name is wrapped
+// in parentheses and may itself contain spaces or parentheses.
+func readProcStat(pid int) (procInfo, error) {
+	b, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return procInfo{}, err
+	}
+	s := string(b)
+	lp, rp := strings.IndexByte(s, '('), strings.LastIndexByte(s, ')')
+	if lp < 0 || rp < lp {
+		return procInfo{}, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+	fields := strings.Fields(s[rp+1:])
+	if len(fields) < 2 {
+		return procInfo{}, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+	ppid, _ := strconv.Atoi(fields[1])
+	return procInfo{pid: pid, ppid: ppid, state: fields[0], comm: s[lp+1 : rp]}, nil
+}
+
+func readProcFile(pid int, name string) string {
+	b, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.ReplaceAll(string(b), "\x00", " "))
+}
+
+// dumpProcTree writes the process rooted at pid and all of its
+// descendants, with state, kernel wait channel and command line.
+func dumpProcTree(w io.Writer, pid int) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		fmt.Fprintf(w, "    cannot read /proc: %v\n", err)
+		return
+	}
+	children := map[int][]int{}
+	procs := map[int]procInfo{}
+	for _, e := range entries {
+		p, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		info, err := readProcStat(p)
+		if err != nil {
+			continue
+		}
+		procs[p] = info
+		children[info.ppid] = append(children[info.ppid], p)
+	}
+	if _, ok := procs[pid]; !ok {
+		fmt.Fprintf(w, "    pid %d no longer exists\n", pid)
+		return
+	}
+	var walk func(p, depth int)
+	walk = func(p, depth int) {
+		info := procs[p]
+		fmt.Fprintf(w, "    %s%d %s (%s) wchan=%s cmd=%s\n",
+			strings.Repeat("  ", depth), p, info.state, info.comm,
+			readProcFile(p, "wchan"), readProcFile(p, "cmdline"))
+		kids := children[p]
+		sort.Ints(kids)
+		for _, k := range kids {
+			walk(k, depth+1)
+		}
+	}
+	walk(pid, 0)
+}
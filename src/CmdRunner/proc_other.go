@@ -0,0 +1,22 @@
+//go:build !linux
+
+package CmdRunner
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+func setProcAttrs(cmd *exec.Cmd) {}
+
+func killProcTree(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
+
+// dumpProcTree is only supported where /proc is available.
+func dumpProcTree(w io.Writer, pid int) {
+	fmt.Fprintf(w, "    process tree dump is not supported on this platform (pid %d)\n", pid)
+}
@@ -0,0 +1,506 @@
+package CmdRunner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bi "go_cmdrX/src/Builtins"
+	ds "go_cmdrX/src/DataStrucs"
+	eu "go_cmdrX/src/ErrUtil"
+	sx "go_cmdrX/src/Secrets"
+)
+
+// How often a running job is checked for wall clock and idle
+// time outs.
+const watchInterval = 250 * time.Millisecond
+
+// JobResult records the outcome of a single command job.
+type JobResult struct {
+	DisplayName  string
+	ExitCode     int
+	StartTime    time.Time
+	EndTime      time.Time
+	TimedOut     bool
+	IdleTimedOut bool
+	Cancelled    bool
+	Skipped      bool
+	UpToDate     bool
+	// Builtin holds the structured result of a Builtin job.
+	Builtin *bi.Result
+	// Err is an *ErrUtil.Error naming the job, whose kind tells
+	//   why it failed.
+	Err error
+}
+
+// Failed reports whether the job result should stop the batch.
+func (r JobResult) Failed() bool {
+	return r.Err != nil
+}
+
+// RunOpts holds the command line options of a batch run.
+type RunOpts struct {
+	// CmdFile is the command file the batch was parsed from. When
+	//   set, job states are checkpointed to a state file.
+	CmdFile string
+	// Resume skips the jobs which succeeded in the checkpointed run.
+	Resume bool
+	// Force runs jobs even when their inputs are up to date.
+	Force bool
+	// Only, when not nil, restricts the run to the jobs whose
+	//   indexes are set.
+	Only map[int]bool
+	// Secrets, when not nil, supplies the values of the jobs'
+	//   "secrets"; every resolved value is masked in the log.
+	Secrets *sx.Store
+}
+
+// Runner executes the jobs of a command batch in order, writing
+// job output and progress messages to Log.
+type Runner struct {
+	Batch ds.JsonCmdBatch
+	Log   io.Writer
+	// State, when not nil, is updated and saved to StatePath as
+	//   each job starts and finishes.
+	State     *BatchState
+	StatePath string
+	Resume    bool
+	// Manifest, when not nil, holds the input fingerprints of the
+	//   last successful run of each job and is saved to ManifestPath.
+	Manifest     *InputManifest
+	ManifestPath string
+	Force        bool
+	Only         map[int]bool
+	Secrets      *sx.Store
+
+	// Guards Log, which a running job's output shares with the
+	//   runner's messages
+	logMu sync.Mutex
+}
+
+// RunBatch opens the batch log file named in the header and runs
+// every job in the batch. If the header does not name a log file,
+// output is written to stdout.
+func RunBatch(ctx context.Context, batch ds.JsonCmdBatch, opts RunOpts) ([]JobResult, error) {
+	r := Runner{Batch: batch, Log: os.Stdout, Resume: opts.Resume, Force: opts.Force,
+		Only: opts.Only, Secrets: opts.Secrets}
+	if opts.CmdFile != "" {
+		if err := r.loadState(opts.CmdFile); err != nil {
+			return nil, err
+		}
+	}
+	if lp := batch.Batch.Hdr.LogPathFileName; lp != "" {
+		f, err := openLogFile(lp)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r.Log = f
+	}
+	if r.Secrets != nil {
+		mw := r.Secrets.Masker().Writer(r.Log)
+		defer mw.Flush()
+		r.Log = mw
+	}
+	return r.Run(ctx)
+}
+
+// loadState prepares the checkpoint for a run of cmdFile. When
+// resuming, the previous state must match the command file content.
+// That is the content the batch was decoded from when the loader
+// recorded its hash, so that an edit made since is not mistaken for
+// what runs.
+func (r *Runner) loadState(cmdFile string) error {
+	hash := r.Batch.SourceSHA256
+	var err error
+	if hash == "" {
+		if hash, err = HashCmdFile(cmdFile); err != nil {
+			return eu.ReadError(err)
+		}
+	}
+	r.StatePath = StatePathFileName(cmdFile, r.Batch.Batch.Hdr)
+	r.ManifestPath = ManifestPathFileName(cmdFile, r.Batch.Batch.Hdr)
+	if r.Manifest, err = LoadInputManifest(r.ManifestPath); err != nil {
+		return err
+	}
+	if !r.Resume {
+		r.State = NewBatchState(cmdFile, hash, r.Batch)
+		return nil
+	}
+	st, err := LoadBatchState(r.StatePath)
+	if err != nil {
+		return fmt.Errorf("cannot resume: %w", err)
+	}
+	if err := st.CheckResumable(hash, r.Batch); err != nil {
+		return fmt.Errorf("cannot resume: %w", err)
+	}
+	r.State = st
+	return nil
+}
+
+// openLogFile opens a log file for appending. A log which cannot be
+// written is a setting the batch cannot run with, so its errors are
+// of kind Validation.
+func openLogFile(logPath string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return nil, &eu.Error{Kind: eu.Validation, Err: fmt.Errorf("Log Directory Error: %w", err)}
+	}
+	f, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, &eu.Error{Kind: eu.Validation, Err: fmt.Errorf("Log File Error: %w", err)}
+	}
+	return f, nil
+}
+
+// Run validates the batch and then executes the jobs in order. No
+// job is run if validation fails. Execution stops at the first
+// failed job; the results of all jobs run so far are returned
+// along with the failing job's error. When resuming, jobs which
+// already succeeded are skipped. Jobs whose inputs are unchanged
+// are skipped as up to date unless Force is set.
+func (r *Runner) Run(ctx context.Context) ([]JobResult, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	var results []JobResult
+	for i, job := range r.Batch.Batch.Jobs {
+		if r.Only != nil && !r.Only[i] {
+			results = append(results, JobResult{DisplayName: job.DisplayName, Skipped: true})
+			continue
+		}
+		if r.Resume && r.State != nil && r.State.Jobs[i].IsDone() {
+			r.logf("=== Job %d %q already succeeded; skipped\n", i+1, job.DisplayName)
+			results = append(results, JobResult{DisplayName: job.DisplayName, Skipped: true})
+			continue
+		}
+		prints, upToDate, err := r.checkUpToDate(i, job)
+		if err != nil {
+			res := JobResult{DisplayName: job.DisplayName, ExitCode: -1,
+				Err: eu.JobError(eu.Validation, i+1, job.DisplayName, err)}
+			results = append(results, res)
+			if err := r.recordJob(i, res); err != nil {
+				return results, err
+			}
+			return results, res.Err
+		}
+		if upToDate {
+			r.logf("=== Job %d %q inputs unchanged; up-to-date\n", i+1, job.DisplayName)
+			results = append(results, JobResult{DisplayName: job.DisplayName, UpToDate: true})
+			if err := r.setJobState(i, JobUpToDate); err != nil {
+				return results, err
+			}
+			continue
+		}
+		if err := r.setJobState(i, JobRunning); err != nil {
+			return results, err
+		}
+		res := r.RunJob(ctx, i, job)
+		results = append(results, res)
+		if err := r.recordJob(i, res); err != nil {
+			return results, err
+		}
+		if res.Failed() {
+			return results, res.Err
+		}
+		if err := r.recordInputs(i, job, prints); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// checkUpToDate fingerprints a job's inputs and compares them with
+// the manifest. The fingerprints are returned so they can be stored
+// once the job succeeds.
+func (r *Runner) checkUpToDate(idx int, job ds.CmdJob) (map[string]string, bool, error) {
+	if r.Manifest == nil || len(job.Inputs) == 0 {
+		return nil, false, nil
+	}
+	dir, err := filepath.Abs(r.JobDir(job))
+	if err != nil {
+		return nil, false, err
+	}
+	var mask *sx.Masker
+	if r.Secrets != nil {
+		mask = r.Secrets.Masker()
+	}
+	prints, err := InputFingerprints(dir, job, mask)
+	if err != nil {
+		return nil, false, err
+	}
+	if r.Force {
+		return prints, false, nil
+	}
+	upToDate := r.Manifest.IsUpToDate(JobKey(idx, job), prints, OutputsExist(dir, job))
+	return prints, upToDate, nil
+}
+
+func (r *Runner) recordInputs(idx int, job ds.CmdJob, prints map[string]string) error {
+	if r.Manifest == nil || prints == nil {
+		return nil
+	}
+	r.Manifest.Jobs[JobKey(idx, job)] = prints
+	if err := r.Manifest.Save(r.ManifestPath); err != nil {
+		return fmt.Errorf("Manifest File Error: %s: %v", r.ManifestPath, err)
+	}
+	return nil
+}
+
+func (r *Runner) setJobState(idx int, state string) error {
+	if r.State == nil {
+		return nil
+	}
+	r.State.Jobs[idx].State = state
+	return r.saveState()
+}
+
+// recordJob checkpoints a finished job. After a failure the jobs
+// which were never reached are marked skipped.
+func (r *Runner) recordJob(idx int, res JobResult) error {
+	if r.State == nil {
+		return nil
+	}
+	r.State.record(idx, res)
+	if r.Secrets != nil {
+		r.State.Jobs[idx].Error = r.Secrets.Masker().Mask(r.State.Jobs[idx].Error)
+	}
+	if res.Failed() {
+		for i := idx + 1; i < len(r.State.Jobs); i++ {
+			r.State.Jobs[i].State = JobSkipped
+		}
+	}
+	return r.saveState()
+}
+
+func (r *Runner) saveState() error {
+	if err := r.State.Save(r.StatePath); err != nil {
+		return fmt.Errorf("State File Error: %s: %v", r.StatePath, err)
+	}
+	return nil
+}
+
+// RunJob launches a single job and waits for it to complete, time
+// out, go idle or be cancelled through ctx.
+func (r *Runner) RunJob(ctx context.Context, idx int, job ds.CmdJob) JobResult {
+	res := JobResult{DisplayName: job.DisplayName, ExitCode: -1}
+	fail := func(kind eu.Kind, err error) JobResult {
+		res.Err = eu.JobError(kind, idx+1, job.DisplayName, err)
+		res.EndTime = time.Now()
+		r.logf("=== Job %d %q failed: %v\n", idx+1, job.DisplayName, err)
+		return res
+	}
+
+	if err := errors.Join(checkJob(job)...); err != nil {
+		return fail(eu.Validation, err)
+	}
+	p, err := r.ResolveJob(idx, job, time.Now())
+	if err != nil {
+		return fail(eu.Validation, err)
+	}
+	if err := sleepUntil(ctx, p.StartAt); err != nil {
+		res.Cancelled = true
+		return fail(eu.Cancelled, err)
+	}
+	log := r.Log
+	if p.LogPath != "" {
+		f, err := openLogFile(p.LogPath)
+		if err != nil {
+			return fail(eu.Launch, err)
+		}
+		defer f.Close()
+		var jw io.Writer = f
+		if r.Secrets != nil {
+			mw := r.Secrets.Masker().Writer(f)
+			defer mw.Flush()
+			jw = mw
+		}
+		log = io.MultiWriter(r.Log, jw)
+	}
+	if bi.IsBuiltinType(p.Type) {
+		return r.runBuiltin(ctx, p, res, log)
+	}
+
+	cmd := exec.Command(p.Argv[0], p.Argv[1:]...)
+	cmd.Dir = p.Dir
+	if len(p.Env) > 0 {
+		cmd.Env = append(os.Environ(), p.Env...)
+	}
+	out := newActivityWriter(lockedWriter{&r.logMu, log})
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.WaitDelay = 5 * time.Second
+	setProcAttrs(cmd)
+
+	res.StartTime = time.Now()
+	r.logf("=== Job %d %q started %s\n", idx+1, job.DisplayName,
+		res.StartTime.Format(time.RFC3339))
+	if err := cmd.Start(); err != nil {
+		return fail(eu.Launch, err)
+	}
+	out.touch()
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var wallC <-chan time.Time
+	if p.TimeOut > 0 {
+		wall := time.NewTimer(p.TimeOut)
+		defer wall.Stop()
+		wallC = wall.C
+	}
+	tick := time.NewTicker(watchInterval)
+	defer tick.Stop()
+
+	var killReason error
+	killKind := eu.Timeout
+	for killReason == nil {
+		select {
+		case err := <-done:
+			return r.finishJob(p, res, err)
+		case <-ctx.Done():
+			res.Cancelled = true
+			killReason, killKind = ctx.Err(), eu.Cancelled
+		case <-wallC:
+			res.TimedOut = true
+			killReason = fmt.Errorf("timed out after %v", p.TimeOut)
+		case <-tick.C:
+			if p.IdleTimeOut > 0 && out.idleFor() >= p.IdleTimeOut {
+				res.IdleTimedOut = true
+				killReason = fmt.Errorf("no output for %v", p.IdleTimeOut)
+				if job.DumpProcTreeOnIdle {
+					r.logf("=== Job %d %q idle; process tree:\n", idx+1, job.DisplayName)
+					dumpProcTree(r.logOut(), cmd.Process.Pid)
+				}
+			}
+		}
+	}
+
+	killProcTree(cmd)
+	<-done
+	if cmd.ProcessState != nil {
+		res.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	return fail(killKind, killReason)
+}
+
+// runBuiltin runs a Builtin job in process, writing its output to
+// log. The job's time out applies; builtins do not stream output, so
+// the idle time out does not. Without exit code thresholds the job
+// fails when the builtin reports failure.
+func (r *Runner) runBuiltin(ctx context.Context, p JobPlan, res JobResult, log io.Writer) JobResult {
+	if p.TimeOut > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.TimeOut)
+		defer cancel()
+	}
+	res.StartTime = time.Now()
+	r.logf("=== Job %d %q started builtin %s\n", p.Index, p.DisplayName, p.Argv[0])
+	br, err := bi.Run(ctx, bi.Env{Dir: p.Dir, Log: log}, p.Argv)
+	res.EndTime = time.Now()
+	res.Builtin = br
+	res.ExitCode = br.ExitCode
+	for _, f := range br.Files {
+		if f.Err != "" {
+			r.logf("    %s %s: %s\n", f.Op, f.Src, f.Err)
+		} else {
+			r.logf("    %s %s %s (%d bytes)\n", f.Op, f.Src, f.Dst, f.Bytes)
+		}
+	}
+	r.logf("=== Job %d %q %s\n", p.Index, p.DisplayName, br.Summary())
+	if err != nil {
+		kind := eu.Launch
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			res.TimedOut = true
+			kind, err = eu.Timeout, fmt.Errorf("timed out after %v", p.TimeOut)
+		case errors.Is(err, context.Canceled):
+			res.Cancelled = true
+			kind = eu.Cancelled
+		}
+		res.Err = eu.JobError(kind, p.Index, p.DisplayName, err)
+		return res
+	}
+	if err := p.ExitCodes.Check(res.ExitCode); err != nil {
+		res.Err = eu.JobError(eu.ExitCode, p.Index, p.DisplayName, err)
+	} else if p.ExitCodes.KillGreaterThan == nil && p.ExitCodes.KillLessThan == nil && br.Failed() {
+		res.Err = eu.JobError(eu.ExitCode, p.Index, p.DisplayName,
+			fmt.Errorf("builtin %s failed with exit code %d", br.Builtin, br.ExitCode))
+	}
+	return res
+}
+
+// finishJob records the exit status of a completed job and applies
+// the job's exit code thresholds.
+func (r *Runner) finishJob(p JobPlan, res JobResult, waitErr error) JobResult {
+	res.EndTime = time.Now()
+	var exitErr *exec.ExitError
+	if waitErr != nil && !errors.As(waitErr, &exitErr) {
+		res.Err = eu.JobError(eu.Launch, p.Index, p.DisplayName, waitErr)
+		r.logf("=== Job %d %q failed: %v\n", p.Index, p.DisplayName, waitErr)
+		return res
+	}
+	res.ExitCode = exitCodeOf(waitErr)
+	r.logf("=== Job %d %q exited with code %d after %v\n", p.Index, p.DisplayName,
+		res.ExitCode, res.EndTime.Sub(res.StartTime).Round(time.Millisecond))
+	if err := p.ExitCodes.Check(res.ExitCode); err != nil {
+		res.Err = eu.JobError(eu.ExitCode, p.Index, p.DisplayName, err)
+	}
+	return res
+}
+
+func exitCodeOf(waitErr error) int {
+	var exitErr *exec.ExitError
+	if errors.As(waitErr, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return 0
+}
+
+// JobDir returns the directory a job executes in: the job's own
+// execute_cmd_in_dir, or the header's command_exe_directory.
+func (r *Runner) JobDir(job ds.CmdJob) string {
+	if job.ExeDir != "" {
+		return job.ExeDir
+	}
+	return r.Batch.Batch.Hdr.CmdExeDirectory
+}
+
+func (r *Runner) logf(format string, a ...interface{}) {
+	fmt.Fprintf(r.logOut(), format, a...)
+}
+
+// logOut returns Log guarded against concurrent writes.
+func (r *Runner) logOut() io.Writer {
+	return lockedWriter{&r.logMu, r.Log}
+}
+
+// JobArgs returns the argv of a job, one element per cmd_elements
+// entry.
+func JobArgs(job ds.CmdJob) []string {
+	var argv []string
+	for _, e := range job.CmdElements {
+		argv = append(argv, e.CmdUnit)
+	}
+	return argv
+}
+
+func sleepUntil(ctx context.Context, t time.Time) error {
+	d := time.Until(t)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,403 @@
+package CmdRunner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	ds "go_cmdrX/src/DataStrucs"
+	eu "go_cmdrX/src/ErrUtil"
+	sx "go_cmdrX/src/Secrets"
+)
+
+func shJob(name, script string, idle time.Duration) ds.CmdJob {
+	return ds.CmdJob{
+		DisplayName:        name,
+		IdleTimeOutMinutes: ds.Minutes{Duration: idle},
+		DumpProcTreeOnIdle: true,
+		CmdElements: []ds.CmdElement{
+			{CmdUnit: "sh"}, {CmdUnit: "-c"}, {CmdUnit: script},
+		},
+	}
+}
+
+func TestIdleTimeOutKillsSilentJob(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	var log bytes.Buffer
+	r := Runner{Log: &log}
+	t.Log("Given a job which prints once and then hangs:")
+	{
+		res := r.RunJob(context.Background(), 0, shJob("Hang", "echo started; sleep 30", 600*time.Millisecond))
+		if !res.IdleTimedOut || !errors.Is(res.Err, eu.Timeout) {
+			t.Fatalf("Expected idle time out. Result: %+v", res)
+		}
+		if res.EndTime.Sub(res.StartTime).Seconds() > 10 {
+			t.Errorf("Job was not killed promptly: %v", res.EndTime.Sub(res.StartTime))
+		}
+		if runtime.GOOS == "linux" && !strings.Contains(log.String(), "process tree") {
+			t.Errorf("Expected process tree dump in log. Log:\n%s", log.String())
+		}
+	}
+}
+
+func TestIdleTimeOutSparesChattyJob(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	var log bytes.Buffer
+	r := Runner{Log: &log}
+	t.Log("Given a slow job which keeps writing output:")
+	{
+		script := "for i in 1 2 3 4; do echo $i; sleep 0.4; done"
+		res := r.RunJob(context.Background(), 0, shJob("Chatty", script, 1200*time.Millisecond))
+		if res.Err != nil || res.ExitCode != 0 {
+			t.Errorf("Expected job to complete. Result: %+v\nLog:\n%s", res, log.String())
+		}
+	}
+}
+
+func TestJobErrorKinds(t *testing.T) {
+	r := Runner{Log: io.Discard}
+	t.Log("Given a job whose executable does not exist:")
+	{
+		job := ds.CmdJob{DisplayName: "Missing", CmdElements: []ds.CmdElement{{CmdUnit: "./no-such-program"}}}
+		if res := r.RunJob(context.Background(), 0, job); !errors.Is(res.Err, eu.Launch) {
+			t.Errorf("Expected a launch failure, got %v", res.Err)
+		}
+	}
+
+	t.Log("Given a delayed job whose run is cancelled:")
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		job := ds.CmdJob{DisplayName: "Later", DelayStartSecs: ds.Seconds{Duration: time.Minute},
+			CmdElements: []ds.CmdElement{{CmdUnit: "true"}}}
+		res := r.RunJob(ctx, 0, job)
+		if !res.Cancelled || !errors.Is(res.Err, eu.Cancelled) || !errors.Is(res.Err, context.Canceled) {
+			t.Errorf("Expected cancellation wrapping context.Canceled, got %v", res.Err)
+		}
+	}
+
+	t.Log("Given a job without command elements:")
+	{
+		res := r.RunJob(context.Background(), 2, ds.CmdJob{DisplayName: "Empty"})
+		if !errors.Is(res.Err, eu.Validation) || res.Err.Error() != `Job 3 "Empty": no command elements` {
+			t.Errorf("Expected a validation error, got %v", res.Err)
+		}
+	}
+}
+
+func builtinJob(name string, argv ...string) ds.CmdJob {
+	job := ds.CmdJob{DisplayName: name, Type: "Builtin"}
+	for _, a := range argv {
+		job.CmdElements = append(job.CmdElements, ds.CmdElement{CmdUnit: a})
+	}
+	return job
+}
+
+func TestFailedBuiltinStopsBatch(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "sums.txt"),
+		[]byte("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  gone.txt\n"), 0644)
+	batch := ds.JsonCmdBatch{}
+	batch.Batch.Hdr.CmdExeDirectory = dir
+	batch.Batch.Jobs = []ds.CmdJob{
+		builtinJob("Verify", "checksum", "--check", "sums.txt"),
+		builtinJob("After", "mkdir", "ran"),
+	}
+	t.Log("Given a checksum job whose listed file is missing, without exit code thresholds:")
+	{
+		r := Runner{Batch: batch, Log: io.Discard}
+		results, err := r.Run(context.Background())
+		if !errors.Is(err, eu.ExitCode) || len(results) != 1 {
+			t.Errorf("Expected the batch to stop at the checksum job, got %v %+v", err, results)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "ran")); err == nil {
+			t.Error("The job after the failed builtin ran")
+		}
+	}
+
+	t.Log("Given a threshold accepting the exit code:")
+	{
+		batch.Batch.Jobs[0].KillOnExitCodeGreaterThan = ds.NewOptInt(1)
+		r := Runner{Batch: batch, Log: io.Discard}
+		if _, err := r.Run(context.Background()); err != nil {
+			t.Errorf("Expected the threshold to decide, got %v", err)
+		}
+	}
+
+	t.Log("Given a sync which copied files:")
+	{
+		os.Mkdir(filepath.Join(dir, "in"), 0755)
+		os.WriteFile(filepath.Join(dir, "in", "a.txt"), []byte("a"), 0644)
+		r := Runner{Log: io.Discard}
+		job := builtinJob("Sync", "sync", "in", "out")
+		job.ExeDir = dir
+		if res := r.RunJob(context.Background(), 0, job); res.Err != nil || res.ExitCode != 1 {
+			t.Errorf("Expected sync's copied bit to be success, got %v exit %d", res.Err, res.ExitCode)
+		}
+	}
+}
+
+func TestFailedHTTPBuiltinStopsBatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, "payload")
+	}))
+	defer srv.Close()
+
+	for _, job := range []ds.CmdJob{
+		builtinJob("Wait", "wait-http", "--timeout", "50ms", "--interval", "5ms", srv.URL+"/down"),
+		builtinJob("Digest", "http", "--sha256", strings.Repeat("0", 64), srv.URL+"/file"),
+		builtinJob("Status", "http", "--status", "204", srv.URL+"/file"),
+	} {
+		t.Logf("Given a %s job which fails, followed by another job:", job.DisplayName)
+		{
+			dir := t.TempDir()
+			batch := ds.JsonCmdBatch{}
+			batch.Batch.Hdr.CmdExeDirectory = dir
+			batch.Batch.Jobs = []ds.CmdJob{job, builtinJob("After", "mkdir", "ran")}
+			r := Runner{Batch: batch, Log: io.Discard}
+			if _, err := r.Run(context.Background()); !errors.Is(err, eu.ExitCode) {
+				t.Errorf("Expected an exit code failure, got %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "ran")); err == nil {
+				t.Error("The job after the failed builtin ran")
+			}
+		}
+	}
+}
+
+func TestResumeSkipsSucceededJobs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	dir := t.TempDir()
+	batch := ds.JsonCmdBatch{}
+	batch.Batch.Jobs = []ds.CmdJob{
+		shJob("One", "echo one >> ran.txt", 0),
+		shJob("Two", "echo two >> ran.txt; test -f ok", 0),
+	}
+	batch.Batch.Jobs[1].KillOnExitCodeGreaterThan = ds.NewOptInt(0)
+	batch.Batch.Hdr.CmdExeDirectory = dir
+	statePath := filepath.Join(dir, "batch.state.json")
+
+	t.Log("Given a batch whose second job fails:")
+	{
+		r := Runner{Batch: batch, Log: io.Discard, StatePath: statePath,
+			State: NewBatchState("batch.json", "hash1", batch)}
+		_, err := r.Run(context.Background())
+		var e *eu.Error
+		if !errors.As(err, &e) || e.Kind != eu.ExitCode || e.JobNo != 2 || e.Job != "Two" {
+			t.Fatalf("Expected first run to fail on job 2's exit code, got %v", err)
+		}
+		os.WriteFile(filepath.Join(dir, "ok"), nil, 0644)
+
+		st, err := LoadBatchState(statePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := st.CheckResumable("hash2", batch); err == nil {
+			t.Error("Expected changed command file hash to be rejected")
+		}
+		r = Runner{Batch: batch, Log: io.Discard, StatePath: statePath, State: st, Resume: true}
+		results, err := r.Run(context.Background())
+		if err != nil {
+			t.Fatalf("Expected resumed run to succeed: %v", err)
+		}
+		if !results[0].Skipped || results[1].Skipped {
+			t.Errorf("Expected only job 1 skipped. Results: %+v", results)
+		}
+		ran, _ := os.ReadFile(filepath.Join(dir, "ran.txt"))
+		if string(ran) != "one\ntwo\ntwo\n" {
+			t.Errorf("Unexpected job executions:\n%s", ran)
+		}
+	}
+}
+
+func TestStateHashesDecodedContent(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	dir := t.TempDir()
+	cmdFile := filepath.Join(dir, "batch.json")
+	os.WriteFile(cmdFile, []byte("edited since it was loaded"), 0644)
+	batch := ds.JsonCmdBatch{SourceSHA256: "decoded"}
+	batch.Batch.Jobs = []ds.CmdJob{shJob("One", "true", 0)}
+	batch.Batch.Hdr.CmdExeDirectory = dir
+
+	t.Log("Given a command file changed after its batch was loaded:")
+	{
+		if _, err := RunBatch(context.Background(), batch, RunOpts{CmdFile: cmdFile}); err != nil {
+			t.Fatal(err)
+		}
+		st, err := LoadBatchState(StatePathFileName(cmdFile, batch.Batch.Hdr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.CmdFileHash != "decoded" {
+			t.Errorf("State hash %q is not that of the content run", st.CmdFileHash)
+		}
+	}
+}
+
+func TestResumeAndLogErrorKinds(t *testing.T) {
+	dir := t.TempDir()
+	cmdFile := filepath.Join(dir, "batch.json")
+	batch := ds.JsonCmdBatch{SourceSHA256: "hash1"}
+	batch.Batch.Jobs = []ds.CmdJob{shJob("One", "true", 0)}
+	batch.Batch.Hdr.CmdExeDirectory = dir
+	statePath := StatePathFileName(cmdFile, batch.Batch.Hdr)
+	os.WriteFile(filepath.Join(dir, "file"), nil, 0644)
+
+	for _, tc := range []struct {
+		given string
+		prep  func()
+		opts  RunOpts
+		log   string
+		kind  eu.Kind
+	}{
+		{"no state file to resume from", func() {}, RunOpts{CmdFile: cmdFile, Resume: true}, "", eu.FileNotFound},
+		{"a state file which does not decode", func() { os.WriteFile(statePath, []byte("{"), 0644) },
+			RunOpts{CmdFile: cmdFile, Resume: true}, "", eu.Parse},
+		{"a state file of other content", func() { NewBatchState(cmdFile, "hash2", batch).Save(statePath) },
+			RunOpts{CmdFile: cmdFile, Resume: true}, "", eu.Validation},
+		{"a log below a file", func() {}, RunOpts{}, filepath.Join(dir, "file", "batch.log"), eu.Validation},
+	} {
+		t.Logf("Given %s:", tc.given)
+		{
+			tc.prep()
+			b := batch
+			b.Batch.Hdr.LogPathFileName = tc.log
+			_, err := RunBatch(context.Background(), b, tc.opts)
+			if k := eu.KindOf(err); err == nil || k != tc.kind {
+				t.Errorf("Expected an error of kind %v, got %v: %v", tc.kind, k, err)
+			}
+		}
+	}
+}
+
+func TestUpToDateJobIsSkipped(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "in.txt"), []byte("v1"), 0644)
+	batch := ds.JsonCmdBatch{}
+	job := shJob("Copy", "cp in.txt out.txt; echo ran >> runs.txt", 0)
+	job.Inputs = []string{"in*.txt"}
+	job.Outputs = []string{"out.txt"}
+	batch.Batch.Jobs = []ds.CmdJob{job}
+	batch.Batch.Hdr.CmdExeDirectory = dir
+	manifest, _ := LoadInputManifest(filepath.Join(dir, "m.json"))
+	run := func(force bool) JobResult {
+		r := Runner{Batch: batch, Log: io.Discard, Manifest: manifest,
+			ManifestPath: filepath.Join(dir, "m.json"), Force: force}
+		results, err := r.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results[0]
+	}
+
+	t.Log("Given a job with declared inputs and outputs:")
+	{
+		if run(false).UpToDate {
+			t.Error("First run must not be up to date")
+		}
+		if !run(false).UpToDate {
+			t.Error("Unchanged inputs must be up to date")
+		}
+		if run(true).UpToDate {
+			t.Error("Force must bypass the up to date check")
+		}
+		os.WriteFile(filepath.Join(dir, "in.txt"), []byte("v2"), 0644)
+		if run(false).UpToDate {
+			t.Error("Changed input must not be up to date")
+		}
+		os.Remove(filepath.Join(dir, "out.txt"))
+		if run(false).UpToDate {
+			t.Error("Missing output must not be up to date")
+		}
+		runs, _ := os.ReadFile(filepath.Join(dir, "runs.txt"))
+		if n := strings.Count(string(runs), "ran"); n != 4 {
+			t.Errorf("Expected 4 executions, got %d", n)
+		}
+	}
+}
+
+func TestInputFingerprintsMaskSecrets(t *testing.T) {
+	dir := t.TempDir()
+	defHash := func(secret string) string {
+		m := &sx.Masker{}
+		m.Add(secret)
+		job := shJob("Push", "push --token="+secret, 0)
+		prints, err := InputFingerprints(dir, job, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return prints[jobDefinitionKey]
+	}
+
+	t.Log("Given a job definition holding an expanded secret:")
+	{
+		if defHash(`hunter2<"&`) != defHash("correct-horse") {
+			t.Error("Job definition hash depends on the secret value")
+		}
+		if defHash("hunter2") == defHash("") {
+			t.Error("Job definition hash ignores the masked arguments")
+		}
+	}
+}
+
+func TestSecretsInjectedAndMasked(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	dir := t.TempDir()
+	store, err := sx.NewStore([]ds.CmdSecret{{Name: "TOKEN", FromEnv: "TEST_TOKEN"}}, dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Getenv = func(string) (string, bool) { return "hunter2-token", true }
+
+	var batch ds.JsonCmdBatch
+	batch.Batch.Hdr.LogPathFileName = filepath.Join(dir, "install.log")
+	withSecret := shJob("Push", `echo "token=$TOKEN"`, 0)
+	withSecret.Secrets = []string{"TOKEN"}
+	withSecret.LogPathFileName = filepath.Join(dir, "jobs", "push.log")
+	batch.Batch.Jobs = []ds.CmdJob{withSecret, shJob("Other", `echo "other=[$TOKEN]"`, 0)}
+
+	t.Log("Given a job referencing a secret and one which does not:")
+	{
+		if _, err := RunBatch(context.Background(), batch, RunOpts{Secrets: store}); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(filepath.Join(dir, "install.log"))
+		log := string(data)
+		if strings.Contains(log, "hunter2") || !strings.Contains(log, "token="+sx.Mask) {
+			t.Errorf("Secret not injected or not masked:\n%s", log)
+		}
+		if !strings.Contains(log, "other=[]") {
+			t.Errorf("Secret leaked into a job which does not reference it:\n%s", log)
+		}
+		data, _ = os.ReadFile(withSecret.LogPathFileName)
+		if got := string(data); got != "token="+sx.Mask+"\n" {
+			t.Errorf("Job log %q, want only the masked job output", got)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package CmdRunner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	ds "go_cmdrX/src/DataStrucs"
+	eu "go_cmdrX/src/ErrUtil"
+)
+
+// Job states recorded in the batch state file.
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobSkipped   = "skipped"
+	JobUpToDate  = "up-to-date"
+)
+
+// BatchState is the checkpoint persisted while a batch runs. It
+// allows a failed batch to be resumed without re-running the jobs
+// which already succeeded.
+type BatchState struct {
+	CmdFile     string     `json:"cmd_file"`
+	CmdFileHash string     `json:"cmd_file_sha256"`
+	Updated     time.Time  `json:"updated"`
+	Jobs        []JobState `json:"jobs"`
+}
+
+// JobState is the persisted state of one job.
+type JobState struct {
+	DisplayName string    `json:"cmd_display_name"`
+	State       string    `json:"state"`
+	ExitCode    int       `json:"exit_code"`
+	StartTime   time.Time `json:"start_time,omitempty"`
+	EndTime     time.Time `json:"end_time,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// NewBatchState returns a state with every job pending.
+func NewBatchState(cmdFile, cmdFileHash string, batch ds.JsonCmdBatch) *BatchState {
+	s := &BatchState{CmdFile: cmdFile, CmdFileHash: cmdFileHash}
+	for _, job := range batch.Batch.Jobs {
+		s.Jobs = append(s.Jobs, JobState{DisplayName: job.DisplayName, State: JobPending})
+	}
+	return s
+}
+
+// StatePathFileName returns the path of the state file kept next
+// to the batch log file. Without a log file the state file is kept
+// next to the command file.
+func StatePathFileName(cmdFile string, hdr ds.CmdHdrDat) string {
+	base := hdr.LogPathFileName
+	if base == "" {
+		base = cmdFile
+	}
+	return strings.TrimSuffix(base, filepath.Ext(base)) + ".state.json"
+}
+
+// HashCmdFile returns the hex SHA-256 of a command file's content.
+func HashCmdFile(fileNamePath string) (string, error) {
+	b, err := os.ReadFile(fileNamePath)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// LoadBatchState reads a state file written by a previous run. A
+// missing file is an error of kind FileNotFound, and one which does
+// not decode of kind Parse.
+func LoadBatchState(statePath string) (*BatchState, error) {
+	b, err := os.ReadFile(statePath)
+	if err != nil {
+		kind := eu.Other
+		if errors.Is(err, fs.ErrNotExist) {
+			kind = eu.FileNotFound
+		}
+		return nil, &eu.Error{Kind: kind, Err: fmt.Errorf("State File Error: %w", err)}
+	}
+	var s BatchState
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, &eu.Error{Kind: eu.Parse, Err: fmt.Errorf("State File Error: %s: %v", statePath, err)}
+	}
+	return &s, nil
+}
+
+// CheckResumable verifies that a saved state belongs to the same,
+// unchanged command file. Its errors are of kind Validation.
+func (s *BatchState) CheckResumable(cmdFileHash string, batch ds.JsonCmdBatch) error {
+	if s.CmdFileHash != cmdFileHash {
+		return &eu.Error{Kind: eu.Validation,
+			Err: fmt.Errorf("command file %s has changed since the saved run; run without --resume", s.CmdFile)}
+	}
+	if len(s.Jobs) != len(batch.Batch.Jobs) {
+		return &eu.Error{Kind: eu.Validation,
+			Err: fmt.Errorf("saved state has %d jobs, command file has %d", len(s.Jobs), len(batch.Batch.Jobs))}
+	}
+	return nil
+}
+
+// Save writes the state file atomically.
+func (s *BatchState) Save(statePath string) error {
+	s.Updated = time.Now()
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
+		return err
+	}
+	tmp := statePath + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, statePath)
+}
+
+// IsDone reports whether the job needs no further run.
+func (js JobState) IsDone() bool {
+	return js.State == JobSucceeded || js.State == JobUpToDate
+}
+
+// record updates job idx from a finished result.
+func (s *BatchState) record(idx int, res JobResult) {
+	js := &s.Jobs[idx]
+	js.ExitCode = res.ExitCode
+	js.StartTime = res.StartTime
+	js.EndTime = res.EndTime
+	js.Error = ""
+	js.State = JobSucceeded
+	if res.Err != nil {
+		js.State = JobFailed
+		js.Error = res.Err.Error()
+	}
+}
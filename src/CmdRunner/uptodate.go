@@ -0,0 +1,207 @@
+package CmdRunner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	ds "go_cmdrX/src/DataStrucs"
+	eu "go_cmdrX/src/ErrUtil"
+	sx "go_cmdrX/src/Secrets"
+)
+
+// Up to date check modes for a job's up_to_date_check.
+const (
+	CheckHash  = "hash"
+	CheckMtime = "mtime"
+)
+
+// InputManifest records the input fingerprints of every job at its
+// last successful run, keyed by JobKey.
+type InputManifest struct {
+	Jobs map[string]map[string]string `json:"jobs"`
+}
+
+// JobKey identifies a job within the manifest. The index keeps
+// jobs with duplicate display names apart.
+func JobKey(idx int, job ds.CmdJob) string {
+	return strconv.Itoa(idx+1) + ":" + job.DisplayName
+}
+
+// ManifestPathFileName returns the path of the input manifest,
+// kept next to the state file.
+func ManifestPathFileName(cmdFile string, hdr ds.CmdHdrDat) string {
+	return strings.TrimSuffix(StatePathFileName(cmdFile, hdr), ".state.json") + ".manifest.json"
+}
+
+// LoadInputManifest reads a manifest file. A missing file yields
+// an empty manifest.
+func LoadInputManifest(manifestPath string) (*InputManifest, error) {
+	m := &InputManifest{Jobs: map[string]map[string]string{}}
+	b, err := os.ReadFile(manifestPath)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, &eu.Error{Kind: eu.Parse, Err: fmt.Errorf("Manifest File Error: %s: %v", manifestPath, err)}
+	}
+	if m.Jobs == nil {
+		m.Jobs = map[string]map[string]string{}
+	}
+	return m, nil
+}
+
+// Save writes the manifest atomically.
+func (m *InputManifest) Save(manifestPath string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := manifestPath + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, manifestPath)
+}
+
+// jobDefinitionKey is the manifest entry holding a hash of the job
+// definition itself, so that editing a job invalidates its inputs.
+const jobDefinitionKey = "<job definition>"
+
+// definition returns the job as JSON with the secret values known to
+// m masked. The masking is done on the decoded strings, as JSON
+// escaping could otherwise hide a value from the masker.
+func definition(job ds.CmdJob, m *sx.Masker) ([]byte, error) {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(maskValue(v, m))
+}
+
+func maskValue(v any, m *sx.Masker) any {
+	switch v := v.(type) {
+	case string:
+		return m.Mask(v)
+	case []any:
+		for i := range v {
+			v[i] = maskValue(v[i], m)
+		}
+	case map[string]any:
+		for k := range v {
+			v[k] = maskValue(v[k], m)
+		}
+	}
+	return v
+}
+
+// InputFingerprints returns a fingerprint for every file matched by
+// the job's input patterns. Directories are walked recursively.
+// Secret values known to m are masked in the hash of the job
+// definition, so that the manifest reveals nothing of them.
+func InputFingerprints(dir string, job ds.CmdJob, m *sx.Masker) (map[string]string, error) {
+	mode := job.UpToDateCheck
+	if mode == "" {
+		mode = CheckHash
+	}
+	if mode != CheckHash && mode != CheckMtime {
+		return nil, fmt.Errorf("invalid up_to_date_check %q", job.UpToDateCheck)
+	}
+	def, err := definition(job, m)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(def)
+	prints := map[string]string{jobDefinitionKey: hex.EncodeToString(sum[:])}
+	for _, pattern := range job.Inputs {
+		matches, err := filepath.Glob(resolvePath(dir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid input pattern %q: %v", pattern, err)
+		}
+		for _, m := range matches {
+			err := filepath.WalkDir(m, func(path string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				fp, err := fingerprint(path, d, mode)
+				if err != nil {
+					return err
+				}
+				prints[path] = fp
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return prints, nil
+}
+
+func fingerprint(path string, d fs.DirEntry, mode string) (string, error) {
+	if mode == CheckMtime {
+		info, err := d.Info()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano()), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// OutputsExist reports whether every output pattern matches at
+// least one existing path.
+func OutputsExist(dir string, job ds.CmdJob) bool {
+	for _, pattern := range job.Outputs {
+		matches, err := filepath.Glob(resolvePath(dir, pattern))
+		if err != nil || len(matches) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// IsUpToDate reports whether a job's inputs match the manifest and
+// its outputs exist. Jobs without inputs are never up to date.
+func (m *InputManifest) IsUpToDate(key string, prints map[string]string, outputsExist bool) bool {
+	old, ok := m.Jobs[key]
+	if !ok || len(old) != len(prints) || !outputsExist {
+		return false
+	}
+	for path, fp := range prints {
+		if old[path] != fp {
+			return false
+		}
+	}
+	return true
+}
+
+func resolvePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
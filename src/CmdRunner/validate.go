@@ -0,0 +1,100 @@
+package CmdRunner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	ds "go_cmdrX/src/DataStrucs"
+	eu "go_cmdrX/src/ErrUtil"
+)
+
+// Validate checks the whole batch before anything is run: values
+// which did not parse, negative time outs and delays, jobs without
+// command elements, display names used twice, and directories which
+// do not exist. Every problem found is returned together, each an
+// *ErrUtil.Error of kind Validation.
+func (r *Runner) Validate() error {
+	var errs []error
+	hdrDir := r.Batch.Batch.Hdr.CmdExeDirectory
+	if err := checkDir(hdrDir); err != nil {
+		errs = append(errs, &eu.Error{Kind: eu.Validation, Err: fmt.Errorf("command_exe_directory: %w", err)})
+	}
+	names := map[string]int{}
+	for i, job := range r.Batch.Batch.Jobs {
+		jobErrs := checkJob(job)
+		if job.DisplayName != "" {
+			if first, ok := names[job.DisplayName]; ok {
+				jobErrs = append(jobErrs, fmt.Errorf("cmd_display_name is also used by job %d", first))
+			} else {
+				names[job.DisplayName] = i + 1
+			}
+		}
+		// The header's directory has been checked already.
+		if job.ExeDir != "" {
+			if err := checkDir(job.ExeDir); err != nil {
+				jobErrs = append(jobErrs, fmt.Errorf("execute_cmd_in_dir: %v", err))
+			}
+		}
+		if err := errors.Join(jobErrs...); err != nil {
+			errs = append(errs, eu.JobError(eu.Validation, i+1, job.DisplayName, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkJob returns the problems of a job which do not depend on the
+// rest of the batch or on the file system.
+func checkJob(job ds.CmdJob) []error {
+	var errs []error
+	for _, f := range []struct {
+		name string
+		err  error
+	}{
+		{"delay_cmd_start_seconds", job.DelayStartSecs.Err()},
+		{"start_cmd_date_time", job.StartAtDateTime.Err()},
+		{"kill_jobs_on_exit_code_greater_than", job.KillOnExitCodeGreaterThan.Err()},
+		{"kill_jobs_on_exit_code_less_than", job.KillOnExitCodeLessThan.Err()},
+		{"cmd_timeout_in_minutes", job.TimeOutMinutes.Err()},
+		{"cmd_idle_timeout_in_minutes", job.IdleTimeOutMinutes.Err()},
+	} {
+		if f.err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %v", f.name, f.err))
+		}
+	}
+	for _, f := range []struct {
+		name string
+		d    ds.Minutes
+	}{
+		{"cmd_timeout_in_minutes", job.TimeOutMinutes},
+		{"cmd_idle_timeout_in_minutes", job.IdleTimeOutMinutes},
+	} {
+		if f.d.Duration < 0 {
+			errs = append(errs, fmt.Errorf("invalid %s: %v is negative", f.name, f.d.Duration))
+		}
+	}
+	if job.DelayStartSecs.Duration < 0 {
+		errs = append(errs, fmt.Errorf("invalid delay_cmd_start_seconds: %v is negative", job.DelayStartSecs.Duration))
+	}
+	if len(job.CmdElements) == 0 {
+		errs = append(errs, errors.New("no command elements"))
+	}
+	return errs
+}
+
+// checkDir reports a directory setting naming a path which does not
+// exist or is not a directory. An empty setting means the current
+// directory and is not checked.
+func checkDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	st, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("directory %q does not exist", dir)
+	}
+	if !st.IsDir() {
+		return fmt.Errorf("%q is not a directory", dir)
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package DataStructs
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The typed job fields below accept the string forms of earlier
+// command files ("15.0", "7", "") as well as plain JSON numbers.
+// A value which does not parse is not a decoding error: its text is
+// kept and reported by Err, so that a validation pass can list every
+// bad value in the batch at once, and so that macros such as
+// %(TIMEOUT)% can be expanded before the value is parsed.
+
+// StartDateTimeFormat is the layout of start_cmd_date_time,
+// interpreted in local time. RFC 3339 times are accepted too.
+const StartDateTimeFormat = "2006-01-02 15:04:05"
+
+// Minutes is a duration written as a decimal number of minutes,
+// such as 15 or "1.5", or as a Go duration such as "1h30m".
+type Minutes struct {
+	time.Duration
+	invalid string
+}
+
+// Seconds is a duration written as a decimal number of seconds or as
+// a Go duration.
+type Seconds struct {
+	time.Duration
+	invalid string
+}
+
+// OptInt is an integer which may be left unset with "" or null.
+type OptInt struct {
+	Value   int
+	Set     bool
+	invalid string
+}
+
+// DateTime is a point in time; the zero value means none.
+type DateTime struct {
+	time.Time
+	invalid string
+}
+
+// NewOptInt returns a set OptInt.
+func NewOptInt(n int) OptInt {
+	return OptInt{Value: n, Set: true}
+}
+
+// Ptr returns the value, or nil when it is unset.
+func (o OptInt) Ptr() *int {
+	if !o.Set {
+		return nil
+	}
+	n := o.Value
+	return &n
+}
+
+func (m Minutes) Err() error { return unitsErr(m.invalid, time.Minute, "minutes") }
+func (s Seconds) Err() error { return unitsErr(s.invalid, time.Second, "seconds") }
+func (o OptInt) Err() error  { return invalidErr(o.invalid, "an integer") }
+func (t DateTime) Err() error {
+	return invalidErr(t.invalid, `a date and time such as "`+StartDateTimeFormat+`"`)
+}
+
+// InvalidValueError is the Err of a typed field whose text does not
+// parse.
+type InvalidValueError struct {
+	Text string
+	// What the text should have been, e.g. "an integer"
+	Want string
+}
+
+func (e *InvalidValueError) Error() string {
+	return fmt.Sprintf("%q is not %s", e.Text, e.Want)
+}
+
+func invalidErr(text, want string) error {
+	if text == "" {
+		return nil
+	}
+	return &InvalidValueError{Text: text, Want: want}
+}
+
+// unitsErr is the Err of a duration, whose text is either not a
+// number or one which is not finite or too large for a Duration.
+func unitsErr(text string, unit time.Duration, units string) error {
+	if _, err := strconv.ParseFloat(strings.TrimSpace(text), 64); text != "" && (err == nil || errors.Is(err, strconv.ErrRange)) {
+		return fmt.Errorf("%q is out of range: expected a finite number of at most %d %s", text, time.Duration(math.MaxInt64)/unit, units)
+	}
+	return invalidErr(text, "a number of "+units)
+}
+
+func (m *Minutes) UnmarshalText(text []byte) error {
+	m.Duration, m.invalid = parseUnits(string(text), time.Minute)
+	return nil
+}
+
+func (s *Seconds) UnmarshalText(text []byte) error {
+	s.Duration, s.invalid = parseUnits(string(text), time.Second)
+	return nil
+}
+
+func (o *OptInt) UnmarshalText(text []byte) error {
+	*o = OptInt{}
+	s := strings.TrimSpace(string(text))
+	if s == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		o.invalid = string(text)
+		return nil
+	}
+	*o = NewOptInt(n)
+	return nil
+}
+
+func (t *DateTime) UnmarshalText(text []byte) error {
+	*t = DateTime{}
+	s := strings.TrimSpace(string(text))
+	if s == "" {
+		return nil
+	}
+	if v, err := time.ParseInLocation(StartDateTimeFormat, s, time.Local); err == nil {
+		t.Time = v
+	} else if v, err := time.Parse(time.RFC3339, s); err == nil {
+		t.Time = v.Local()
+	} else {
+		t.invalid = string(text)
+	}
+	return nil
+}
+
+func (m Minutes) MarshalText() ([]byte, error) {
+	return unitsText(m.Duration, time.Minute, m.invalid), nil
+}
+
+func (s Seconds) MarshalText() ([]byte, error) {
+	return unitsText(s.Duration, time.Second, s.invalid), nil
+}
+
+func (o OptInt) MarshalText() ([]byte, error) {
+	switch {
+	case o.invalid != "":
+		return []byte(o.invalid), nil
+	case !o.Set:
+		return nil, nil
+	}
+	return []byte(strconv.Itoa(o.Value)), nil
+}
+
+func (t DateTime) MarshalText() ([]byte, error) {
+	switch {
+	case t.invalid != "":
+		return []byte(t.invalid), nil
+	case t.IsZero():
+		return nil, nil
+	}
+	return []byte(t.Local().Format(StartDateTimeFormat)), nil
+}
+
+func (m *Minutes) UnmarshalJSON(data []byte) error { return unmarshalJSONText(data, m) }
+func (s *Seconds) UnmarshalJSON(data []byte) error { return unmarshalJSONText(data, s) }
+func (o *OptInt) UnmarshalJSON(data []byte) error  { return unmarshalJSONText(data, o) }
+func (t *DateTime) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONText(data, t)
+}
+
+// Durations and integers are written as JSON numbers once they
+// parse; unset values as null and unparsed ones as strings.
+
+func (m Minutes) MarshalJSON() ([]byte, error) { return marshalJSONNumber(m, m.invalid) }
+func (s Seconds) MarshalJSON() ([]byte, error) { return marshalJSONNumber(s, s.invalid) }
+func (o OptInt) MarshalJSON() ([]byte, error) {
+	if !o.Set && o.invalid == "" {
+		return []byte("null"), nil
+	}
+	return marshalJSONNumber(o, o.invalid)
+}
+
+func (t DateTime) MarshalJSON() ([]byte, error) {
+	text, _ := t.MarshalText()
+	return json.Marshal(string(text))
+}
+
+type textValue interface {
+	UnmarshalText(text []byte) error
+	MarshalText() ([]byte, error)
+}
+
+// unmarshalJSONText decodes a JSON string or number through the
+// value's text form. Other JSON values are kept as invalid text.
+func unmarshalJSONText(data []byte, v textValue) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		return v.UnmarshalText(nil)
+	case len(data) > 0 && data[0] == '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		return v.UnmarshalText([]byte(s))
+	case len(data) > 0 && (data[0] == '-' || data[0] >= '0' && data[0] <= '9'):
+		return v.UnmarshalText(data)
+	}
+	// An object, array or boolean: keep it so that Err reports it.
+	setInvalid(v, string(data))
+	return nil
+}
+
+func setInvalid(v textValue, text string) {
+	switch x := v.(type) {
+	case *Minutes:
+		x.Duration, x.invalid = 0, text
+	case *Seconds:
+		x.Duration, x.invalid = 0, text
+	case *OptInt:
+		*x = OptInt{invalid: text}
+	case *DateTime:
+		*x = DateTime{invalid: text}
+	}
+}
+
+func marshalJSONNumber(v encoding.TextMarshaler, invalid string) ([]byte, error) {
+	text, err := v.MarshalText()
+	if err != nil || invalid != "" {
+		return json.Marshal(string(text))
+	}
+	return text, nil
+}
+
+// parseUnits parses a decimal number of units or a Go duration,
+// returning the text itself as invalid when it is neither, or when
+// the number is not finite or overflows a Duration.
+// Negative values parse; validation rejects them.
+func parseUnits(text string, unit time.Duration) (time.Duration, string) {
+	s := strings.TrimSpace(text)
+	if s == "" {
+		return 0, ""
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil || errors.Is(err, strconv.ErrRange) {
+		// NaN fails the comparison as well.
+		if d := f * float64(unit); err == nil && math.Abs(d) < math.MaxInt64 {
+			return time.Duration(d), ""
+		}
+		return 0, text
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, ""
+	}
+	return 0, text
+}
+
+func unitsText(d, unit time.Duration, invalid string) []byte {
+	if invalid != "" {
+		return []byte(invalid)
+	}
+	return []byte(strconv.FormatFloat(float64(d)/float64(unit), 'f', -1, 64))
+}
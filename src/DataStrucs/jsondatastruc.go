@@ -1,36 +1,207 @@
 package DataStructs
 
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// CurrentSchemaVersion is the schema_version of command files
+// written by this version of cmdrx. Version 1 is the earlier
+// commands/commandfileheader/exectutecommands layout.
+const CurrentSchemaVersion = 2
+
+// DecodeOptions are the settings of command file decoders.
+type DecodeOptions struct {
+	// Ignore unknown keys, as encoding/json does, rather than
+	//   reporting them.
+	Lenient bool
+}
+
+// ExtensionPrefix starts keys of custom annotations, which every
+// decoder accepts and ignores at any level of a command file.
+const ExtensionPrefix = "x-"
+
+// IsExtensionKey reports whether key is a custom annotation.
+func IsExtensionKey(key string) bool { return strings.HasPrefix(key, ExtensionPrefix) }
+
+// JsonCmdBatch is a command file: a header and the jobs run in
+// order.
 type JsonCmdBatch struct {
-	Batch CmdHdr `json:"commands_batch"`
+	// Location of this schema, for editors
+	Schema string `json:"$schema,omitempty"`
+	// Layout generation of the file; see CurrentSchemaVersion.
+	//   Loaded batches always hold the current version.
+	SchemaVersion int    `json:"schema_version" jsonschema:"enum=2"`
+	Batch         CmdHdr `json:"commands_batch" jsonschema:"required"`
+
+	// Hex SHA-256 of the command file content the batch was decoded
+	//   from, set by the loader
+	SourceSHA256 string `json:"-"`
 }
 
 type CmdHdr struct {
-	Hdr  CmdHdrDat `json:"jobs_header"`
-	Jobs []CmdJob  `json:"command_jobs"`
+	// Settings shared by all jobs
+	Hdr CmdHdrDat `json:"jobs_header"`
+	// Named values referenced in job fields as %(name)% and set
+	//   on the command line with -p name=value.
+	Params []CmdParam `json:"parameters"`
+	// Jobs run in order; the batch stops at the first failure
+	Jobs []CmdJob `json:"command_jobs" jsonschema:"required"`
+	// Named overlays selected with --profile
+	Profiles map[string]CmdProfile `json:"profiles,omitempty"`
+}
+
+// CmdProfile overlays a batch. Header and job overlays are partial
+// objects merged field by field: objects merge recursively,
+// anything else replaces the base value and null clears it.
+type CmdProfile struct {
+	// Name of a profile applied before this one
+	Extends string `json:"extends,omitempty"`
+	// Partial jobs_header merged into the batch's header
+	Hdr json.RawMessage `json:"jobs_header,omitempty"`
+	// Overlays of the jobs with the same cmd_display_name. An
+	//   overlay matching no job is appended as an extra job.
+	Jobs []json.RawMessage `json:"command_jobs,omitempty"`
+	// Display names of jobs left out of the batch
+	RemoveJobs []string `json:"remove_jobs,omitempty"`
+}
+
+// ProfileOverlay returns the type overlaid by field i of struct type
+// t when that field is one of CmdProfile's raw overlays, or nil.
+// Overlays are only merged once a profile is applied, so decoders
+// check them against this type instead.
+func ProfileOverlay(t reflect.Type, i int) reflect.Type {
+	if t != reflect.TypeOf(CmdProfile{}) {
+		return nil
+	}
+	switch t.Field(i).Name {
+	case "Hdr":
+		return reflect.TypeOf(CmdHdrDat{})
+	case "Jobs":
+		return reflect.TypeOf([]CmdJob{})
+	}
+	return nil
+}
+
+// CmdParam declares a batch parameter.
+type CmdParam struct {
+	// Referenced as %(name)% and set with -p name=value
+	Name string `json:"name" jsonschema:"required"`
+	// "string" (default), "int", "bool", "path" or "enum"
+	Type string `json:"type" jsonschema:"enum=|string|int|bool|path|enum"`
+	// Value used when the parameter is not set
+	Default string `json:"default"`
+	// The run fails when a required parameter is not set
+	Required bool `json:"required"`
+	// Shown by 'cmdrx run --help-params'
+	Desc string `json:"description"`
+	// Allowed values of an "enum" parameter
+	Values []string `json:"values"`
 }
 
+// CmdHdrDat holds the settings shared by all jobs. As with every
+// type here, its fields are exported so that encoding/json can set
+// them.
 type CmdHdrDat struct {
-	// JSON Conversion works as long as first letter of field
-	//   is capitalized
-	LogFileRetentionInDays int    `json:"log_file_retention_in_days"`
-	CmdExeDirectory        string `json:"command_exe_directory"`
-	LogPathFileName        string `json:"log_path_file_name"`
+	// Days the batch logs are kept
+	LogFileRetentionInDays int `json:"log_file_retention_in_days"`
+	// Directory of jobs without an execute_cmd_in_dir
+	CmdExeDirectory string `json:"command_exe_directory"`
+	// Log file of the whole batch
+	LogPathFileName string `json:"log_path_file_name"`
+	// Extra paths or glob patterns watched by 'cmdrx watch'.
+	//   A change below any of them re-runs the whole batch.
+	WatchPaths []string `json:"watch_paths"`
+	// Used by 'cmdrx daemon': a cron expression such as
+	//   "30 2 * * 1-5", a descriptor such as "@daily" or an
+	//   interval such as "@every 4h".
+	Schedule string `json:"schedule"`
+	// "skip" (default) or "queue" a run which falls due while
+	//   the previous run is still active.
+	ScheduleOverlap string `json:"schedule_overlap" jsonschema:"enum=|skip|queue"`
+	// Runs missed while the daemon was down: "none" (default),
+	//   "once" or "all".
+	ScheduleCatchUp string `json:"schedule_catch_up" jsonschema:"enum=|none|once|all"`
+	// Go time layouts of the %(CURDATESTR)%, %(CURTIMESTR)% and
+	//   %(CURDATETIMESTR)% macros. Empty selects the defaults.
+	MacroDateFormat     string `json:"macro_date_format"`
+	MacroTimeFormat     string `json:"macro_time_format"`
+	MacroDateTimeFormat string `json:"macro_date_time_format"`
+	// Values referenced as %(SECRET:name)% or listed in a job's
+	//   "secrets". They are masked in all output.
+	Secrets []CmdSecret `json:"secrets"`
+}
+
+// CmdSecret declares a secret value and where it is read from.
+type CmdSecret struct {
+	Name string `json:"name" jsonschema:"required"`
+	// Exactly one source: an environment variable, a file holding
+	//   the value, or an encrypted secrets file written with
+	//   'cmdrx secrets set'.
+	FromEnv           string `json:"from_env"`
+	FromFile          string `json:"from_file"`
+	FromEncryptedFile string `json:"from_encrypted_file"`
+	// Entry in the encrypted file; defaults to the name.
+	Key string `json:"key"`
 }
 
+// CmdJob is one command of the batch.
 type CmdJob struct {
-	DisplayName               string       `json:"cmd_display_name"`
-	Desc                      string       `json:"cmd_description"`
-	Type                      string       `json:"cmd_type"`
-	ExeDir                    string       `json:"execute_cmd_in_dir"`
-	DelayStartSecs            string       `json:"delay_cmd_start_seconds"`
-	StartAtDateTime           string       `json:"start_cmd_date_time"`
-	KillOnExitCodeGreaterThan string       `json:"kill_jobs_on_exit_code_greater_than"`
-	KillOnExitCodeLessThan    string       `json:"kill_jobs_on_exit_code_less_than"`
-	TimeOutMinutes            string       `json:"cmd_timeout_in_minutes"`
-	CmdElements               []CmdElement `json:"cmd_elements"`
+	// Names the job in logs; unique within the batch
+	DisplayName string `json:"cmd_display_name"`
+	Desc        string `json:"cmd_description"`
+	// "Console" runs cmd_elements as a process, "Builtin" runs
+	//   the builtin named by the first element
+	Type string `json:"cmd_type" jsonschema:"enum=Console|Builtin"`
+	// Directory the job runs in; defaults to the header's
+	//   command_exe_directory
+	ExeDir string `json:"execute_cmd_in_dir"`
+	// Wait before the job starts
+	DelayStartSecs Seconds `json:"delay_cmd_start_seconds"`
+	// The job does not start before this local time. Overrides
+	//   delay_cmd_start_seconds.
+	StartAtDateTime DateTime `json:"start_cmd_date_time"`
+	// The batch stops when the job's exit code is greater than
+	//   or less than these. Unset thresholds are not checked.
+	KillOnExitCodeGreaterThan OptInt `json:"kill_jobs_on_exit_code_greater_than"`
+	KillOnExitCodeLessThan    OptInt `json:"kill_jobs_on_exit_code_less_than"`
+	// Job is killed when it runs longer. Empty or zero means no
+	//   limit.
+	TimeOutMinutes Minutes `json:"cmd_timeout_in_minutes"`
+	// Job is killed when it writes nothing to stdout or stderr
+	//   for this many minutes. Empty or zero disables the check.
+	IdleTimeOutMinutes Minutes `json:"cmd_idle_timeout_in_minutes"`
+	// Log the job's process tree before an idle time out kill
+	DumpProcTreeOnIdle bool `json:"dump_proc_tree_on_idle_timeout"`
+	// Glob patterns, relative to the job directory. A job with
+	//   inputs is skipped when its inputs are unchanged since the
+	//   last successful run and all of its outputs exist.
+	Inputs  []string `json:"inputs"`
+	Outputs []string `json:"outputs"`
+	// "hash" (default) or "mtime"
+	UpToDateCheck string `json:"up_to_date_check" jsonschema:"enum=|hash|mtime"`
+	// Job output is also appended to this file
+	LogPathFileName string `json:"cmd_log_path_file_name"`
+	// Secrets exported to the job's environment under their names
+	Secrets []string `json:"secrets"`
+	// The command line, one argument per element
+	CmdElements []CmdElement `json:"cmd_elements" jsonschema:"required"`
 }
 
 type CmdElement struct {
+	// One command line argument, passed without shell quoting
 	CmdUnit string `json:"cmdelement"`
 }
 
+// UnmarshalJSON also accepts an element written as a plain string,
+// the short form used in YAML command files and profile overlays.
+func (e *CmdElement) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		e.CmdUnit = s
+		return nil
+	}
+	type plain CmdElement
+	return json.Unmarshal(data, (*plain)(e))
+}
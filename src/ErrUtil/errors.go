@@ -0,0 +1,150 @@
+// Package ErrUtil classifies the errors of cmdrx, so that callers
+// such as the cmdrx exit status can tell a command file which does
+// not parse from a job which timed out.
+//
+// An *Error has a Kind and wraps its cause. Kinds are errors too, so
+// the kind of any error wrapping an *Error is tested with errors.Is:
+//
+//	if errors.Is(err, ErrUtil.Timeout) { ... }
+//
+// and its details, such as the job, are read with errors.As.
+package ErrUtil
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// Kind is the class of an error.
+type Kind int
+
+const (
+	// Errors of no other kind
+	Other Kind = iota
+	// A command file or other input which does not exist
+	FileNotFound
+	// A command file which is not valid JSON, YAML, TOML or XML,
+	// or whose values do not fit their settings
+	Parse
+	// A command file which parses but cannot be run as written,
+	// e.g. a missing directory or an unset required parameter
+	Validation
+	// A job whose process could not be started
+	Launch
+	// A job stopped by its time out or idle time out
+	Timeout
+	// A job whose exit code is outside its kill_jobs_on_exit_code
+	// thresholds
+	ExitCode
+	// A job stopped by cancellation, e.g. of an interrupted daemon
+	Cancelled
+)
+
+var kindNames = [...]string{"error", "file not found", "parse error", "validation error",
+	"launch failure", "timeout", "exit code threshold", "cancelled"}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+	return kindNames[k]
+}
+
+// Error makes a Kind the target of errors.Is.
+func (k Kind) Error() string { return k.String() }
+
+// Error is an error of a known kind. Its message is that of its
+// cause, prefixed with the file and job when they are set.
+type Error struct {
+	Kind Kind
+	// Command file concerned, if any
+	File string
+	// Position in File of the first problem, when known
+	Line, Col int
+	// 1-based index and display name of the job concerned;
+	//   JobNo is zero for errors of no one job.
+	JobNo int
+	Job   string
+	Err   error
+}
+
+func (e *Error) Error() string {
+	var sb strings.Builder
+	if e.File != "" {
+		sb.WriteString(e.File + ": ")
+	}
+	if e.JobNo > 0 {
+		fmt.Fprintf(&sb, "Job %d %q: ", e.JobNo, e.Job)
+	}
+	if e.Err != nil {
+		sb.WriteString(e.Err.Error())
+	} else {
+		sb.WriteString(e.Kind.String())
+	}
+	return sb.String()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Is reports whether target is the Kind of e.
+func (e *Error) Is(target error) bool {
+	k, ok := target.(Kind)
+	return ok && k == e.Kind
+}
+
+// JobError returns err as an error of job number idx, counted
+// from 1.
+func JobError(kind Kind, idx int, job string, err error) *Error {
+	return &Error{Kind: kind, JobNo: idx, Job: job, Err: err}
+}
+
+// FileError returns err as an error in file, with the position of
+// the first Positioned error it wraps.
+func FileError(kind Kind, file string, err error) *Error {
+	e := &Error{Kind: kind, File: file, Err: err}
+	var p Positioned
+	if errors.As(err, &p) {
+		e.Line, e.Col = p.Position()
+	}
+	return e
+}
+
+// ReadError returns the error of reading a command file, of kind
+// FileNotFound when the file does not exist.
+func ReadError(err error) *Error {
+	kind := Other
+	if errors.Is(err, fs.ErrNotExist) {
+		kind = FileNotFound
+	}
+	return &Error{Kind: kind, Err: fmt.Errorf("Command File Error: %w", err)}
+}
+
+// KindOf returns the kind of the first *Error wrapped by err, in the
+// order errors.As searches, or Other.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return Other
+}
+
+// Positioned is an error at a line and column of a file.
+type Positioned interface {
+	error
+	Position() (line, col int)
+}
+
+// PositionError is a message about a line and column of a file.
+type PositionError struct {
+	Line, Col int
+	Msg       string
+}
+
+func (e *PositionError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Col, e.Msg)
+}
+
+func (e *PositionError) Position() (line, col int) { return e.Line, e.Col }
@@ -0,0 +1,52 @@
+package ErrUtil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestErrorKinds(t *testing.T) {
+	t.Log("Given a job error wrapped by a caller:")
+	{
+		cause := errors.New("exit code 3 is greater than 0")
+		err := fmt.Errorf("batch stopped: %w", JobError(ExitCode, 2, "Copy", cause))
+		if err.Error() != `batch stopped: Job 2 "Copy": exit code 3 is greater than 0` {
+			t.Errorf("Message %q", err)
+		}
+		if !errors.Is(err, ExitCode) || errors.Is(err, Timeout) || !errors.Is(err, cause) {
+			t.Errorf("errors.Is does not follow the kind and cause of %v", err)
+		}
+		var e *Error
+		if !errors.As(err, &e) || e.Job != "Copy" || KindOf(err) != ExitCode {
+			t.Errorf("errors.As gave %+v", e)
+		}
+		if KindOf(cause) != Other {
+			t.Errorf("Plain errors are of kind %v", KindOf(cause))
+		}
+	}
+
+	t.Log("Given positioned errors joined in a file error:")
+	{
+		err := FileError(Parse, "cmds.yaml", errors.Join(
+			errors.New("empty value"),
+			&PositionError{Line: 4, Col: 7, Msg: "jobs[0]: unknown key"},
+			&PositionError{Line: 9, Col: 1, Msg: "jobs[1]: unknown key"}))
+		if err.Line != 4 || err.Col != 7 {
+			t.Errorf("Position %d:%d", err.Line, err.Col)
+		}
+		want := "cmds.yaml: empty value\nline 4, column 7: jobs[0]: unknown key\nline 9, column 1: jobs[1]: unknown key"
+		if err.Error() != want {
+			t.Errorf("Message %q", err)
+		}
+	}
+
+	t.Log("Given a command file which cannot be read:")
+	{
+		_, err := os.ReadFile("no-such-file.json")
+		if e := ReadError(err); e.Kind != FileNotFound || !errors.Is(e, os.ErrNotExist) {
+			t.Errorf("Read error %v of kind %v", e, e.Kind)
+		}
+	}
+}
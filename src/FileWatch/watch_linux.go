@@ -0,0 +1,112 @@
+package FileWatch
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ATTRIB
+
+// inotifyWatch watches directory trees with inotify. New sub
+// directories are added as they are created.
+type inotifyWatch struct {
+	f    *os.File
+	fd   int
+	mu   sync.Mutex
+	dirs map[int32]string
+}
+
+func startWatch(w *Watcher, roots []string) (watcherImpl, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	iw := &inotifyWatch{f: os.NewFile(uintptr(fd), "inotify"), fd: fd, dirs: map[int32]string{}}
+	for _, r := range roots {
+		if err := iw.addTree(r); err != nil {
+			iw.close()
+			return nil, err
+		}
+	}
+	go iw.readEvents(w)
+	return iw, nil
+}
+
+func (iw *inotifyWatch) addTree(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		wd, err := syscall.InotifyAddWatch(iw.fd, path, inotifyMask)
+		if err != nil {
+			return os.NewSyscallError("inotify_add_watch "+path, err)
+		}
+		iw.mu.Lock()
+		iw.dirs[int32(wd)] = path
+		iw.mu.Unlock()
+		return nil
+	})
+}
+
+func (iw *inotifyWatch) readEvents(w *Watcher) {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := iw.f.Read(buf)
+		if err != nil {
+			select {
+			case <-w.done:
+			default:
+				w.sendErr(err)
+			}
+			return
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameBytes := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(ev.Len)]
+			off += syscall.SizeofInotifyEvent + int(ev.Len)
+
+			iw.mu.Lock()
+			dir, ok := iw.dirs[ev.Wd]
+			if ev.Mask&syscall.IN_IGNORED != 0 {
+				delete(iw.dirs, ev.Wd)
+			}
+			iw.mu.Unlock()
+			if !ok {
+				continue
+			}
+			path := filepath.Join(dir, cString(nameBytes))
+			if ev.Mask&syscall.IN_ISDIR != 0 && ev.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+				if err := iw.addTree(path); err != nil {
+					w.sendErr(err)
+				}
+			}
+			if ev.Mask&syscall.IN_IGNORED == 0 {
+				w.send(path)
+			}
+		}
+	}
+}
+
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
+
+func (iw *inotifyWatch) close() error {
+	return iw.f.Close()
+}
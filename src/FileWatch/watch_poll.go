@@ -0,0 +1,72 @@
+//go:build !linux
+
+package FileWatch
+
+import (
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// PollInterval is how often directory trees are rescanned where
+// inotify is not available.
+var PollInterval = time.Second
+
+type pollWatch struct {
+	stop chan struct{}
+}
+
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func startWatch(w *Watcher, roots []string) (watcherImpl, error) {
+	pw := &pollWatch{stop: make(chan struct{})}
+	prev := scanTrees(roots)
+	go func() {
+		tick := time.NewTicker(PollInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-pw.stop:
+				return
+			case <-tick.C:
+			}
+			cur := scanTrees(roots)
+			for path, st := range cur {
+				if old, ok := prev[path]; !ok || old != st {
+					w.send(path)
+				}
+			}
+			for path := range prev {
+				if _, ok := cur[path]; !ok {
+					w.send(path)
+				}
+			}
+			prev = cur
+		}
+	}()
+	return pw, nil
+}
+
+func scanTrees(roots []string) map[string]fileStamp {
+	stamps := map[string]fileStamp{}
+	for _, r := range roots {
+		filepath.WalkDir(r, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				stamps[path] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+			}
+			return nil
+		})
+	}
+	return stamps
+}
+
+func (pw *pollWatch) close() error {
+	close(pw.stop)
+	return nil
+}
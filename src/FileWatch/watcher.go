@@ -0,0 +1,118 @@
+package FileWatch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Watcher reports changes below a set of root directories. The path
+// of every created, written, removed or renamed file is sent on
+// Events.
+type Watcher struct {
+	Events chan string
+	Errors chan error
+	done   chan struct{}
+	impl   watcherImpl
+}
+
+type watcherImpl interface {
+	close() error
+}
+
+// NewWatcher starts watching the directories which hold the given
+// paths or glob patterns. Directories are watched recursively.
+func NewWatcher(patterns []string) (*Watcher, error) {
+	w := &Watcher{
+		Events: make(chan string, 64),
+		Errors: make(chan error, 1),
+		done:   make(chan struct{}),
+	}
+	impl, err := startWatch(w, WatchRoots(patterns))
+	if err != nil {
+		return nil, err
+	}
+	w.impl = impl
+	return w, nil
+}
+
+// Close stops the watcher.
+func (w *Watcher) Close() error {
+	close(w.done)
+	return w.impl.close()
+}
+
+func (w *Watcher) send(path string) {
+	select {
+	case w.Events <- path:
+	case <-w.done:
+	}
+}
+
+func (w *Watcher) sendErr(err error) {
+	select {
+	case w.Errors <- err:
+	default:
+	}
+}
+
+// WatchRoots returns the existing directories to watch for a set
+// of paths or glob patterns: the longest directory prefix of each
+// pattern without glob meta characters. Nested roots are dropped.
+func WatchRoots(patterns []string) []string {
+	var roots []string
+	for _, p := range patterns {
+		dir := staticPrefix(filepath.Clean(p))
+		for {
+			if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+				break
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		roots = append(roots, dir)
+	}
+	var out []string
+	for _, r := range roots {
+		nested := false
+		for _, o := range roots {
+			if o != r && IsUnder(r, o) {
+				nested = true
+				break
+			}
+		}
+		if !nested && !contains(out, r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func staticPrefix(pattern string) string {
+	i := strings.IndexAny(pattern, "*?[")
+	if i < 0 {
+		return pattern
+	}
+	return filepath.Dir(pattern[:i] + "x")
+}
+
+// IsUnder reports whether path equals dir or lies below it.
+func IsUnder(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package FileWatch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcherReportsNestedChanges(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWatcher([]string{filepath.Join(dir, "*.txt")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	sub := filepath.Join(dir, "sub")
+	os.Mkdir(sub, 0755)
+	time.Sleep(100 * time.Millisecond)
+	target := filepath.Join(sub, "a.txt")
+	os.WriteFile(target, []byte("x"), 0644)
+
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case p := <-w.Events:
+			if p == target {
+				return
+			}
+		case err := <-w.Errors:
+			t.Fatal(err)
+		case <-deadline:
+			t.Fatalf("No event for %s", target)
+		}
+	}
+}
+
+func TestWatchRoots(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "src"), 0755)
+	roots := WatchRoots([]string{
+		filepath.Join(dir, "src", "*.go"),
+		filepath.Join(dir, "src", "missing", "file.txt"),
+		filepath.Join(dir, "src"),
+	})
+	if len(roots) != 1 || roots[0] != filepath.Join(dir, "src") {
+		t.Errorf("Unexpected roots: %v", roots)
+	}
+}
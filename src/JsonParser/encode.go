@@ -0,0 +1,119 @@
+package JsonParser
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	ds "go_cmdrX/src/DataStrucs"
+)
+
+// EncodeJSONCmds writes a batch as an indented JSON command file of
+// the current schema version. Fields holding their zero value are
+// left out, so that a migrated file has only the settings of the
+// original.
+func EncodeJSONCmds(batch ds.JsonCmdBatch) ([]byte, error) {
+	batch.SchemaVersion = ds.CurrentSchemaVersion
+	var buf bytes.Buffer
+	if err := encodeValue(&buf, reflect.ValueOf(batch), ""); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+var marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+type member struct {
+	name  string
+	value reflect.Value
+}
+
+func encodeValue(buf *bytes.Buffer, v reflect.Value, indent string) error {
+	switch {
+	case v.Type().Implements(marshalerType):
+		// Typed settings such as time outs.
+	case v.Kind() == reflect.Struct:
+		var members []member
+		for i := 0; i < v.NumField(); i++ {
+			name := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+			if name != "" && name != "-" && !isEmpty(v.Field(i)) {
+				members = append(members, member{name, v.Field(i)})
+			}
+		}
+		return encodeMembers(buf, members, indent)
+	case v.Kind() == reflect.Map:
+		var members []member
+		for _, k := range v.MapKeys() {
+			members = append(members, member{k.String(), v.MapIndex(k)})
+		}
+		sort.Slice(members, func(i, j int) bool { return members[i].name < members[j].name })
+		return encodeMembers(buf, members, indent)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8:
+		if v.Len() == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteString("[\n")
+		for i := 0; i < v.Len(); i++ {
+			buf.WriteString(indent + "  ")
+			if err := encodeValue(buf, v.Index(i), indent+"  "); err != nil {
+				return err
+			}
+			if i < v.Len()-1 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent + "]")
+		return nil
+	}
+	return encodeLeaf(buf, v.Interface())
+}
+
+func encodeMembers(buf *bytes.Buffer, members []member, indent string) error {
+	if len(members) == 0 {
+		buf.WriteString("{}")
+		return nil
+	}
+	buf.WriteString("{\n")
+	for i, m := range members {
+		buf.WriteString(indent + "  ")
+		if err := encodeLeaf(buf, m.name); err != nil {
+			return err
+		}
+		buf.WriteString(": ")
+		if err := encodeValue(buf, m.value, indent+"  "); err != nil {
+			return err
+		}
+		if i < len(members)-1 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('\n')
+	}
+	buf.WriteString(indent + "}")
+	return nil
+}
+
+// encodeLeaf writes a scalar or raw JSON value without escaping
+// the characters <, > and &, which are common in command lines.
+func encodeLeaf(buf *bytes.Buffer, x interface{}) error {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(x); err != nil {
+		return err
+	}
+	buf.Write(bytes.TrimSuffix(b.Bytes(), []byte("\n")))
+	return nil
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
@@ -0,0 +1,119 @@
+package JsonParser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// A DecodeError is a problem at a position in a JSON command file.
+// Its message is followed by the offending line with a caret under
+// the column.
+type DecodeError struct {
+	Offset    int
+	Line, Col int
+	// Field path such as commands_batch.command_jobs[1].cmd_type;
+	//   empty for syntax errors.
+	Path    string
+	Msg     string
+	Snippet string
+}
+
+func (e *DecodeError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "line %d, column %d: ", e.Line, e.Col)
+	if e.Path != "" {
+		sb.WriteString(e.Path + ": ")
+	}
+	sb.WriteString(e.Msg)
+	if e.Snippet != "" {
+		sb.WriteString("\n" + e.Snippet)
+	}
+	return sb.String()
+}
+
+// Position returns the line and column of the error.
+func (e *DecodeError) Position() (line, col int) { return e.Line, e.Col }
+
+func newDecodeError(data []byte, offset int, path, msg string) *DecodeError {
+	line, col := Position(data, offset)
+	return &DecodeError{Offset: offset, Line: line, Col: col, Path: path, Msg: msg,
+		Snippet: snippet(data, offset)}
+}
+
+// Position returns the 1-based line and column of a byte offset.
+// Columns count characters, as editors do.
+func Position(data []byte, offset int) (line, col int) {
+	offset = min(max(offset, 0), len(data))
+	line, start := 1, 0
+	for i := 0; i < offset; i++ {
+		if data[i] == '\n' {
+			line, start = line+1, i+1
+		}
+	}
+	return line, utf8.RuneCount(data[start:offset]) + 1
+}
+
+// Characters of a long line shown either side of the column.
+const snippetContext = 60
+
+// snippet returns the line holding offset, indented, and below it a
+// caret under the offset. Long lines are cut around the offset.
+func snippet(data []byte, offset int) string {
+	offset = min(max(offset, 0), len(data))
+	start, end := offset, offset
+	for start > 0 && data[start-1] != '\n' {
+		start--
+	}
+	for end < len(data) && data[end] != '\n' && data[end] != '\r' {
+		end++
+	}
+	before := []rune(string(data[start:offset]))
+	after := []rune(string(data[offset:end]))
+	prefix, suffix := "", ""
+	if len(before) > snippetContext {
+		before, prefix = before[len(before)-snippetContext:], "..."
+	}
+	if len(after) > snippetContext {
+		after, suffix = after[:snippetContext], "..."
+	}
+	// Keep the tabs of the line so that the caret lines up.
+	var caret strings.Builder
+	caret.WriteString(strings.Repeat(" ", len(prefix)))
+	for _, r := range before {
+		if r == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteRune(' ')
+		}
+	}
+	return "    " + prefix + string(before) + string(after) + suffix + "\n    " + caret.String() + "^"
+}
+
+// jsonError gives the errors of encoding/json a position.
+func jsonError(data []byte, err error) error {
+	var syn *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syn):
+		// The offset follows the offending character.
+		return newDecodeError(data, int(syn.Offset)-1, "", strings.TrimPrefix(syn.Error(), "json: "))
+	case errors.As(err, &typ):
+		return newDecodeError(data, int(typ.Offset), typ.Field,
+			fmt.Sprintf("expected %s, found %s", typ.Type, typ.Value))
+	}
+	return err
+}
+
+// joinByPosition joins errors, those with a position in document
+// order.
+func joinByPosition(errs []error) error {
+	sort.SliceStable(errs, func(a, b int) bool {
+		var da, db *DecodeError
+		return errors.As(errs[a], &da) && errors.As(errs[b], &db) && da.Offset < db.Offset
+	})
+	return errors.Join(errs...)
+}
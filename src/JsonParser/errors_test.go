@@ -0,0 +1,119 @@
+package JsonParser
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	ds "go_cmdrX/src/DataStrucs"
+)
+
+func TestDecodeErrorPositions(t *testing.T) {
+	t.Log("Given the prototype file missing a closing bracket:")
+	{
+		data, err := os.ReadFile("../../prototype/ReadJSON/ReadCmd/06_InvalidFileErr/CmdrX_JSON_006Bad.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = DecodeJSONCmds(strings.NewReader(string(data)), ds.DecodeOptions{})
+		want := "line 33, column 7: unexpected '}', expecting ',' or ']'\n" +
+			"          },\n" +
+			"          ^"
+		if err == nil || err.Error() != want {
+			t.Errorf("Expected:\n%s\ngot:\n%v", want, err)
+		}
+	}
+
+	t.Log("Given a file with several syntax slips and type errors:")
+	{
+		doc := `{"commands_batch": {
+  "jobs_header": {"log_file_retention_in_days": "30"},
+  "command_jobs": [
+    {"cmd_display_name": "A", "cmd_elements": ["echo",]},
+    {"cmd_display_name": "B" "cmd_timeout_in_minutes": [15],
+     "cmd_elements": [{"cmdelement": 1}], "delay_cmd_start_seconds": 1e300}
+  ]}}`
+		_, err := DecodeJSONCmds(strings.NewReader(doc), ds.DecodeOptions{})
+		if err == nil {
+			t.Fatal("Expected errors")
+		}
+		var got []string
+		for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+			var de *DecodeError
+			if !errors.As(e, &de) {
+				t.Fatalf("Expected a DecodeError, got %T: %v", e, e)
+			}
+			got = append(got, strings.SplitN(de.Error(), "\n", 2)[0])
+		}
+		want := []string{
+			"line 2, column 49: commands_batch.jobs_header.log_file_retention_in_days: expected an integer, found \"30\"",
+			"line 4, column 55: trailing comma before ']'",
+			"line 5, column 30: missing ',' before key",
+			"line 5, column 56: commands_batch.command_jobs[1].cmd_timeout_in_minutes: expected a number of minutes, found array",
+			"line 6, column 38: commands_batch.command_jobs[1].cmd_elements[0].cmdelement: expected a string, found number 1",
+			"line 6, column 70: commands_batch.command_jobs[1].delay_cmd_start_seconds: \"1e300\" is out of range: expected a finite number of at most 9223372036 seconds",
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+		}
+	}
+
+	t.Log("Given arrays nested beyond the depth limit:")
+	{
+		doc := `{"commands_batch": ` + strings.Repeat("[", 3_000_000)
+		_, err := DecodeJSONCmds(strings.NewReader(doc), ds.DecodeOptions{})
+		var de *DecodeError
+		if !errors.As(err, &de) || !strings.Contains(de.Msg, "exceeded max depth") || de.Col != 19+maxDepth {
+			t.Errorf("Expected a depth error at column %d, got %v", 19+maxDepth, de)
+		}
+	}
+
+	t.Log("Given a long line:")
+	{
+		line := `{"commands_batch": {"command_jobs": [{"cmd_display_name": "` + strings.Repeat("x", 100) + `", "cmd_type": 1}]}}`
+		_, err := DecodeJSONCmds(strings.NewReader(line), ds.DecodeOptions{})
+		lines := strings.Split(err.Error(), "\n")
+		if len(lines) != 3 || !strings.HasPrefix(lines[1], "    ...") || strings.Index(lines[2], "^") != strings.Index(lines[1], "1}") {
+			t.Errorf("Expected a cut snippet with the caret under the value, got:\n%v", err)
+		}
+	}
+}
+
+func TestUnknownKeys(t *testing.T) {
+	doc := `{"$schema": "cmdrx.schema.json", "x-owner": "ops",
+  "commands_batch": {"command_jobs": [
+    {"cmd_display_name": "A", "cmd_timout_in_minutes": 5, "x-note": {"any": 1},
+     "cmd_elements": ["ls", {"cmdelement": "-l", "id": 2}]}
+  ],
+  "profiles": {"prod": {"jobs_header": {"log_path_fle_name": "p.log", "x-why": 1},
+    "command_jobs": [{"cmd_display_name": "A", "cmd_typ": "Console"}]}}}}`
+	t.Log("Given misspelt keys and annotations:")
+	{
+		_, err := DecodeJSONCmds(strings.NewReader(doc), ds.DecodeOptions{})
+		if err == nil {
+			t.Fatal("Expected errors")
+		}
+		var got []string
+		for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+			got = append(got, strings.SplitN(e.Error(), "\n", 2)[0])
+		}
+		want := []string{
+			`line 3, column 31: commands_batch.command_jobs[0].cmd_timout_in_minutes: unknown key; did you mean "cmd_timeout_in_minutes"?`,
+			`line 4, column 50: commands_batch.command_jobs[0].cmd_elements[1].id: unknown key`,
+			`line 6, column 41: commands_batch.profiles.prod.jobs_header.log_path_fle_name: unknown key; did you mean "log_path_file_name"?`,
+			`line 7, column 48: commands_batch.profiles.prod.command_jobs[0].cmd_typ: unknown key; did you mean "cmd_type"?`,
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+		}
+	}
+
+	t.Log("Given lenient decoding:")
+	{
+		b, err := DecodeJSONCmds(strings.NewReader(doc), ds.DecodeOptions{Lenient: true})
+		if err != nil || len(b.Batch.Jobs) != 1 || b.Batch.Jobs[0].TimeOutMinutes.Duration != 0 {
+			t.Errorf("Expected the unknown keys ignored, got %v %+v", err, b.Batch.Jobs)
+		}
+	}
+}
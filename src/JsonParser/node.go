@@ -0,0 +1,355 @@
+package JsonParser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// NodeKind is the JSON type of a Node.
+type NodeKind int
+
+const (
+	Null NodeKind = iota
+	Bool
+	Number
+	String
+	Array
+	Object
+)
+
+var kindNames = [...]string{"null", "boolean", "number", "string", "array", "object"}
+
+func (k NodeKind) String() string { return kindNames[k] }
+
+// A Node is a JSON value together with its position in the source,
+// for messages which point at the offending text.
+type Node struct {
+	Kind NodeKind
+	// Byte offsets of the value's first character and of the
+	//   character following it
+	Offset, End int
+	// Scalars: a bool, a json.Number or a string
+	Value   interface{}
+	Items   []*Node
+	Members []Member
+}
+
+// A Member is a key and value of an object, in source order.
+type Member struct {
+	Key       string
+	KeyOffset int
+	Value     *Node
+}
+
+// Describe names a value in messages: scalars are shown, arrays and
+// objects named.
+func (n *Node) Describe() string {
+	switch n.Kind {
+	case String:
+		return strconv.Quote(n.Value.(string))
+	case Number:
+		return "number " + string(n.Value.(json.Number))
+	case Bool:
+		return fmt.Sprint(n.Value)
+	}
+	return n.Kind.String()
+}
+
+// ParseNode parses a JSON document, keeping the position of every
+// value. Common slips, such as a missing comma or closing bracket
+// or a trailing comma, are reported and parsing continues as though
+// they had been corrected, so that one pass reports several of them.
+// The node returned is then the corrected document; it is nil when
+// parsing could not continue. Errors are *DecodeError values joined
+// with errors.Join.
+func ParseNode(data []byte) (*Node, error) {
+	n, errs := parseNode(data)
+	return n, errors.Join(errs...)
+}
+
+func parseNode(data []byte) (*Node, []error) {
+	p := nodeParser{data: data}
+	n, err := p.value()
+	if err == nil {
+		p.space()
+		if p.pos < len(p.data) {
+			err = p.errorf("unexpected %s after the document", p.found())
+		}
+	}
+	if err != nil {
+		p.errs = append(p.errs, err)
+		n = nil
+	}
+	return n, p.errs
+}
+
+// maxDepth limits the nesting of arrays and objects, as in
+// encoding/json, so that a hostile file cannot exhaust the stack.
+const maxDepth = 10000
+
+type nodeParser struct {
+	data []byte
+	pos  int
+	// Arrays and objects open at pos
+	depth int
+	// Errors recovered from
+	errs []error
+}
+
+func (p *nodeParser) errorf(format string, a ...interface{}) error {
+	return newDecodeError(p.data, p.pos, "", fmt.Sprintf(format, a...))
+}
+
+// recovered records an error parsing continues after.
+func (p *nodeParser) recovered(format string, a ...interface{}) {
+	p.errs = append(p.errs, p.errorf(format, a...))
+}
+
+// found describes the text at the current position.
+func (p *nodeParser) found() string {
+	if p.pos >= len(p.data) {
+		return "end of input"
+	}
+	r, _ := utf8.DecodeRune(p.data[p.pos:])
+	return fmt.Sprintf("%q", r)
+}
+
+func (p *nodeParser) space() {
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// peek returns the next byte, or 0 at the end of the input.
+func (p *nodeParser) peek() byte {
+	if p.pos < len(p.data) {
+		return p.data[p.pos]
+	}
+	return 0
+}
+
+func startsValue(c byte) bool {
+	switch c {
+	case '{', '[', '"', '-', 't', 'f', 'n':
+		return true
+	}
+	return c >= '0' && c <= '9'
+}
+
+func (p *nodeParser) value() (*Node, error) {
+	p.space()
+	if p.pos >= len(p.data) {
+		return nil, p.errorf("unexpected end of input, expecting a value")
+	}
+	n := &Node{Offset: p.pos}
+	err := p.scalarOrContainer(n)
+	n.End = p.pos
+	return n, err
+}
+
+// enter opens an array or object, failing beyond maxDepth.
+func (p *nodeParser) enter() error {
+	if p.depth++; p.depth > maxDepth {
+		return p.errorf("exceeded max depth of %d nested arrays and objects", maxDepth)
+	}
+	return nil
+}
+
+func (p *nodeParser) scalarOrContainer(n *Node) error {
+	var err error
+	switch c := p.data[p.pos]; {
+	case c == '{':
+		return p.object(n)
+	case c == '[':
+		return p.array(n)
+	case c == '"':
+		n.Kind = String
+		n.Value, err = p.str()
+		return err
+	case c == '-' || c >= '0' && c <= '9':
+		n.Kind = Number
+		n.Value, err = p.number()
+		return err
+	}
+	for _, lit := range []struct {
+		text  string
+		kind  NodeKind
+		value interface{}
+	}{{"true", Bool, true}, {"false", Bool, false}, {"null", Null, nil}} {
+		if len(p.data)-p.pos >= len(lit.text) && string(p.data[p.pos:p.pos+len(lit.text)]) == lit.text {
+			p.pos += len(lit.text)
+			n.Kind, n.Value = lit.kind, lit.value
+			return nil
+		}
+	}
+	return p.errorf("unexpected %s, expecting a value", p.found())
+}
+
+func (p *nodeParser) object(n *Node) error {
+	n.Kind = Object
+	if err := p.enter(); err != nil {
+		return err
+	}
+	defer func() { p.depth-- }()
+	p.pos++
+	p.space()
+	if p.peek() == '}' {
+		p.pos++
+		return nil
+	}
+	for {
+		p.space()
+		if p.peek() != '"' {
+			return p.errorf("unexpected %s, expecting a quoted key", p.found())
+		}
+		m := Member{KeyOffset: p.pos}
+		key, err := p.str()
+		if err != nil {
+			return err
+		}
+		m.Key = key
+		p.space()
+		if p.peek() != ':' {
+			return p.errorf("unexpected %s after key %q, expecting ':'", p.found(), key)
+		}
+		p.pos++
+		if m.Value, err = p.value(); err != nil {
+			return err
+		}
+		n.Members = append(n.Members, m)
+		p.space()
+		switch c := p.peek(); {
+		case c == ',':
+			p.pos++
+			p.space()
+			if p.peek() == '}' {
+				p.recovered("trailing comma before '}'")
+				p.pos++
+				return nil
+			}
+		case c == '}':
+			p.pos++
+			return nil
+		case c == '"':
+			p.recovered("missing ',' before key")
+		case c == ']':
+			// Take it as a missing '}' and leave the ']' to the
+			// enclosing array.
+			p.recovered("unexpected ']', expecting ',' or '}'")
+			return nil
+		default:
+			return p.errorf("unexpected %s, expecting ',' or '}'", p.found())
+		}
+	}
+}
+
+func (p *nodeParser) array(n *Node) error {
+	n.Kind = Array
+	if err := p.enter(); err != nil {
+		return err
+	}
+	defer func() { p.depth-- }()
+	p.pos++
+	p.space()
+	if p.peek() == ']' {
+		p.pos++
+		return nil
+	}
+	for {
+		item, err := p.value()
+		if err != nil {
+			return err
+		}
+		n.Items = append(n.Items, item)
+		p.space()
+		switch c := p.peek(); {
+		case c == ',':
+			p.pos++
+			p.space()
+			if p.peek() == ']' {
+				p.recovered("trailing comma before ']'")
+				p.pos++
+				return nil
+			}
+		case c == ']':
+			p.pos++
+			return nil
+		case c == '}':
+			// Take it as a missing ']' and leave the '}' to the
+			// enclosing object.
+			p.recovered("unexpected '}', expecting ',' or ']'")
+			return nil
+		case startsValue(c):
+			p.recovered("missing ',' before value")
+		default:
+			return p.errorf("unexpected %s, expecting ',' or ']'", p.found())
+		}
+	}
+}
+
+// str scans a string and decodes it with encoding/json, which
+// checks its escapes.
+func (p *nodeParser) str() (string, error) {
+	start := p.pos
+	for p.pos++; p.pos < len(p.data); p.pos++ {
+		switch c := p.data[p.pos]; {
+		case c == '\\':
+			p.pos++
+		case c == '"':
+			p.pos++
+			var s string
+			if err := json.Unmarshal(p.data[start:p.pos], &s); err != nil {
+				p.pos = start
+				return "", p.errorf("invalid string: %v", err)
+			}
+			return s, nil
+		case c < 0x20:
+			return "", p.errorf("control character %q in string", c)
+		}
+	}
+	p.pos = start
+	return "", p.errorf("unterminated string")
+}
+
+func (p *nodeParser) number() (json.Number, error) {
+	start := p.pos
+	digits := func() int {
+		n := 0
+		for p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '9' {
+			p.pos, n = p.pos+1, n+1
+		}
+		return n
+	}
+	if p.data[p.pos] == '-' {
+		p.pos++
+	}
+	if p.peek() == '0' {
+		p.pos++
+	} else if digits() == 0 {
+		return "", p.errorf("unexpected %s in number", p.found())
+	}
+	if p.peek() == '.' {
+		p.pos++
+		if digits() == 0 {
+			return "", p.errorf("unexpected %s in number", p.found())
+		}
+	}
+	if c := p.peek(); c == 'e' || c == 'E' {
+		p.pos++
+		if c := p.peek(); c == '+' || c == '-' {
+			p.pos++
+		}
+		if digits() == 0 {
+			return "", p.errorf("unexpected %s in number", p.found())
+		}
+	}
+	return json.Number(p.data[start:p.pos]), nil
+}
@@ -1,22 +1,53 @@
 package JsonParser
 
 import(
+	"errors"
+	"fmt"
 	ds "go_cmdrX/src/DataStrucs"
 	eu "go_cmdrX/src/ErrUtil"
 	"os"
 	"io"
-	"encoding/json"
 )
 
 
-func ParseJSONCmds(fileNamePath string) ds.JsonCmdBatch {
+// ParseJSONCmds reads a JSON command file.
+func ParseJSONCmds(fileNamePath string) (ds.JsonCmdBatch, error) {
 	f, err := os.Open(fileNamePath)
+	if err != nil {
+		return ds.JsonCmdBatch{}, eu.ReadError(err)
+	}
 	defer f.Close()
-	eu.SpecCheckErr("Command File Error: " + fileNamePath + "\n", err)
-	var JObj ds.JsonCmdBatch
-	rdr := io.Reader(f)
-	err = json.NewDecoder(rdr).Decode(&JObj)
-	eu.SpecCheckErr("JSON Parsing Error Cmd File: "  + fileNamePath + "\n", err)
-	return JObj
+	JObj, err := DecodeJSONCmds(f, ds.DecodeOptions{})
+	if err != nil {
+		return JObj, eu.FileError(eu.Parse, fileNamePath, fmt.Errorf("JSON Parsing Error: %w", err))
+	}
+	return JObj, nil
 }
 
+// DecodeJSONCmds decodes a JSON command file of any schema version
+// into the current model, returning errors rather than ending the
+// program. Syntax errors and values of the wrong type are reported
+// with their line, column and field path, as many of them at once
+// as can be found. Unknown keys are errors too unless opts are
+// lenient; keys starting with x- are always accepted.
+func DecodeJSONCmds(rdr io.Reader, opts ds.DecodeOptions) (ds.JsonCmdBatch, error) {
+	data, err := io.ReadAll(rdr)
+	if err != nil {
+		return ds.JsonCmdBatch{}, err
+	}
+	n, errs := parseNode(data)
+	if n == nil {
+		return ds.JsonCmdBatch{}, errors.Join(errs...)
+	}
+	v, err := nodeVersion(data, n)
+	if err != nil {
+		return ds.JsonCmdBatch{}, joinByPosition(append(errs, err))
+	}
+	errs = append(errs, checkTypes(data, n, schemas[v].model, opts)...)
+	if len(errs) > 0 {
+		return ds.JsonCmdBatch{}, joinByPosition(errs)
+	}
+	JObj, err := schemas[v].decode(data)
+	JObj.SchemaVersion = ds.CurrentSchemaVersion
+	return JObj, err
+}
@@ -0,0 +1,158 @@
+package JsonParser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	ds "go_cmdrX/src/DataStrucs"
+)
+
+// A schema decodes one generation of the JSON command file into the
+// current batch model.
+type schema struct {
+	// Top-level key of the generation, used to recognise files
+	//   without a schema_version.
+	key string
+	// Go type of the whole file, against which the types of its
+	//   values are checked
+	model  reflect.Type
+	decode func(data []byte) (ds.JsonCmdBatch, error)
+}
+
+var schemas = map[int]schema{
+	1: {"commands", reflect.TypeOf(v1File{}), decodeV1},
+	2: {"commands_batch", reflect.TypeOf(ds.JsonCmdBatch{}), decodeV2},
+}
+
+// SchemaVersion returns the schema version of a JSON command file:
+// its schema_version or, in a file without one, the version whose
+// top-level object it has. A file matching no version is an error
+// rather than an empty batch.
+func SchemaVersion(data []byte) (int, error) {
+	n, errs := parseNode(data)
+	if len(errs) > 0 {
+		return 0, errors.Join(errs...)
+	}
+	return nodeVersion(data, n)
+}
+
+func nodeVersion(data []byte, n *Node) (int, error) {
+	if n.Kind != Object {
+		return 0, newDecodeError(data, n.Offset, "", "not a command file: expected an object, found "+n.Describe())
+	}
+	top := map[string]*Node{}
+	for _, m := range n.Members {
+		top[m.Key] = m.Value
+	}
+	if sv, ok := top["schema_version"]; ok {
+		v, err := 0, error(nil)
+		if sv.Kind == Number {
+			v, err = strconv.Atoi(string(sv.Value.(json.Number)))
+		}
+		if sv.Kind != Number || err != nil {
+			return 0, newDecodeError(data, sv.Offset, "schema_version", "expected an integer, found "+sv.Describe())
+		}
+		s, ok := schemas[v]
+		if !ok {
+			return v, fmt.Errorf("schema_version %d is not supported; this cmdrx reads versions 1 to %d",
+				v, ds.CurrentSchemaVersion)
+		}
+		if _, ok := top[s.key]; !ok {
+			return v, fmt.Errorf("schema_version %d files need a top-level %q object", v, s.key)
+		}
+		return v, nil
+	}
+	for v := ds.CurrentSchemaVersion; v > 0; v-- {
+		if _, ok := top[schemas[v].key]; ok {
+			return v, nil
+		}
+	}
+	var keys []string
+	for k := range top {
+		keys = append(keys, fmt.Sprintf("%q", k))
+	}
+	sort.Strings(keys)
+	found := "no keys"
+	if len(keys) > 0 {
+		found = strings.Join(keys, ", ")
+	}
+	return 0, fmt.Errorf("not a command file: no top-level %q object (found %s)",
+		schemas[ds.CurrentSchemaVersion].key, found)
+}
+
+func decodeV2(data []byte) (ds.JsonCmdBatch, error) {
+	var JObj ds.JsonCmdBatch
+	err := json.Unmarshal(data, &JObj)
+	return JObj, jsonError(data, err)
+}
+
+// Version 1 files, such as json/CmdrXCmds002.json, name the
+// executable separately from its id-numbered arguments.
+type v1File struct {
+	SchemaVersion int `json:"schema_version"`
+	Commands      struct {
+		Hdr struct {
+			LogFileRetentionInDays int    `json:"defaultlogfileretentionindays"`
+			CmdExeDirectory        string `json:"defaultcommandexedirectory"`
+			LogPathFileName        string `json:"default_cmd_log_path_file_name"`
+		} `json:"commandfileheader"`
+		Jobs []v1Job `json:"exectutecommands"`
+	} `json:"commands"`
+}
+
+type v1Job struct {
+	DisplayName               string     `json:"cmd_display_name"`
+	Desc                      string     `json:"cmd_description"`
+	Type                      string     `json:"console_cmd_type"`
+	KillOnExitCodeGreaterThan ds.OptInt  `json:"kill_jobs_on_exit_code_greater_than"`
+	KillOnExitCodeLessThan    ds.OptInt  `json:"kill_jobs_on_exit_code_less_than"`
+	LogPathFileName           string     `json:"cmd_log_path_file_name"`
+	TimeOutMinutes            ds.Minutes `json:"cmd_timeout_in_minutes"`
+	ExeDir                    string     `json:"execute_cmd_in_dir"`
+	Executor                  string     `json:"cmd_executor"`
+	CmdElements               []struct {
+		ID      int    `json:"id"`
+		CmdUnit string `json:"cmdelement"`
+	} `json:"cmd_elements"`
+}
+
+// decodeV1 decodes a version 1 file. The executor becomes the
+// first argv element, followed by the elements in id order.
+func decodeV1(data []byte) (ds.JsonCmdBatch, error) {
+	var batch ds.JsonCmdBatch
+	var f v1File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return batch, fmt.Errorf("schema version 1: %w", jsonError(data, err))
+	}
+	hdr := &batch.Batch.Hdr
+	hdr.LogFileRetentionInDays = f.Commands.Hdr.LogFileRetentionInDays
+	hdr.CmdExeDirectory = f.Commands.Hdr.CmdExeDirectory
+	hdr.LogPathFileName = f.Commands.Hdr.LogPathFileName
+	for _, j := range f.Commands.Jobs {
+		job := ds.CmdJob{
+			DisplayName:               j.DisplayName,
+			Desc:                      j.Desc,
+			Type:                      j.Type,
+			ExeDir:                    j.ExeDir,
+			KillOnExitCodeGreaterThan: j.KillOnExitCodeGreaterThan,
+			KillOnExitCodeLessThan:    j.KillOnExitCodeLessThan,
+			TimeOutMinutes:            j.TimeOutMinutes,
+			LogPathFileName:           j.LogPathFileName,
+		}
+		if j.Executor != "" {
+			job.CmdElements = append(job.CmdElements, ds.CmdElement{CmdUnit: j.Executor})
+		}
+		elems := j.CmdElements
+		sort.SliceStable(elems, func(a, b int) bool { return elems[a].ID < elems[b].ID })
+		for _, e := range elems {
+			job.CmdElements = append(job.CmdElements, ds.CmdElement{CmdUnit: e.CmdUnit})
+		}
+		batch.Batch.Jobs = append(batch.Batch.Jobs, job)
+	}
+	return batch, nil
+}
@@ -0,0 +1,66 @@
+package JsonParser
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	ds "go_cmdrX/src/DataStrucs"
+)
+
+func TestSchemaVersions(t *testing.T) {
+	t.Log("Given the version 1 file shipped in json/:")
+	{
+		data, err := os.ReadFile("../../json/CmdrXCmds002.json")
+		if err != nil {
+			t.Skip(err)
+		}
+		b, err := DecodeJSONCmds(bytes.NewReader(data), ds.DecodeOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.SchemaVersion != ds.CurrentSchemaVersion || b.Batch.Hdr.LogPathFileName != "./cmdrx/install.log" || len(b.Batch.Jobs) != 2 {
+			t.Fatalf("Upgraded batch %+v", b)
+		}
+		j := b.Batch.Jobs[0]
+		var argv []string
+		for _, e := range j.CmdElements {
+			argv = append(argv, e.CmdUnit)
+		}
+		if j.Type != "Console" || strings.Join(argv, "|") != `cmd.exe|/c|Copy|D:\T06\*.* D:\T08\` {
+			t.Errorf("Job %+v", j)
+		}
+
+		t.Log("When it is encoded in the current version:")
+		out, err := EncodeJSONCmds(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := SchemaVersion(out); v != ds.CurrentSchemaVersion || err != nil {
+			t.Errorf("Encoded file has version %d, %v", v, err)
+		}
+		if bytes.Contains(out, []byte(`""`)) || bytes.Contains(out, []byte(`\u003e`)) {
+			t.Errorf("Empty fields or escapes in output:\n%s", out)
+		}
+		again, err := DecodeJSONCmds(bytes.NewReader(out), ds.DecodeOptions{})
+		if err != nil || len(again.Batch.Jobs) != 2 || again.Batch.Jobs[0].TimeOutMinutes.Duration != 15*time.Minute {
+			t.Errorf("Round trip: %+v, %v", again, err)
+		}
+	}
+
+	t.Log("Given files of no known layout:")
+	{
+		for doc, want := range map[string]string{
+			`{"command_batch": {}}`:                       `no top-level "commands_batch" object (found "command_batch")`,
+			`{"schema_version": 9, "commands_batch": {}}`: "schema_version 9 is not supported",
+			`{"schema_version": 1, "commands_batch": {}}`: `schema_version 1 files need a top-level "commands" object`,
+			`{"commands": {"exectutecommand": []}}`:       `commands.exectutecommand: unknown key; did you mean "exectutecommands"?`,
+		} {
+			if _, err := DecodeJSONCmds(strings.NewReader(doc), ds.DecodeOptions{}); err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: got %v, want %q", doc, err, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package JsonParser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	ds "go_cmdrX/src/DataStrucs"
+	"go_cmdrX/src/stringmgr/suggest"
+)
+
+var (
+	rawMessageType  = reflect.TypeOf(json.RawMessage(nil))
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// checkTypes reports every value of a document which does not fit
+// the Go type t, naming it by its field path. encoding/json stops at
+// the first such value and gives only its byte offset. Unless opts
+// are lenient, keys matching no field are reported too.
+func checkTypes(data []byte, n *Node, t reflect.Type, opts ds.DecodeOptions) []error {
+	c := typeChecker{data: data, strict: !opts.Lenient}
+	c.check(n, t, "")
+	return c.errs
+}
+
+type typeChecker struct {
+	data   []byte
+	strict bool
+	errs   []error
+}
+
+func (c *typeChecker) fail(n *Node, path, format string, a ...interface{}) {
+	c.errs = append(c.errs, newDecodeError(c.data, n.Offset, path, fmt.Sprintf(format, a...)))
+}
+
+func (c *typeChecker) check(n *Node, t reflect.Type, path string) {
+	// encoding/json leaves a value unchanged by null.
+	if n.Kind == Null || t == rawMessageType {
+		return
+	}
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		// Objects decoded as structs, such as a cmd_elements entry,
+		// are checked field by field for their keys too.
+		if n.Kind == Object && t.Kind() == reflect.Struct {
+			c.fields(n, t, path)
+			return
+		}
+		v := reflect.New(t)
+		if err := v.Interface().(json.Unmarshaler).UnmarshalJSON(c.data[n.Offset:n.End]); err != nil {
+			c.fail(n, path, "%v", err)
+			return
+		}
+		// Typed settings keep a string which does not parse for
+		// validation, since a macro may make it valid. Values of
+		// any other JSON type cannot become valid.
+		if e, ok := v.Interface().(interface{ Err() error }); ok && n.Kind != String {
+			var ie *ds.InvalidValueError
+			if err := e.Err(); errors.As(err, &ie) {
+				c.fail(n, path, "expected %s, found %s", ie.Want, n.Describe())
+			} else if err != nil {
+				c.fail(n, path, "%v", err)
+			}
+		}
+		return
+	}
+	switch t.Kind() {
+	case reflect.String:
+		if n.Kind != String {
+			c.fail(n, path, "expected a string, found %s", n.Describe())
+		}
+	case reflect.Int:
+		if n.Kind != Number {
+			c.fail(n, path, "expected an integer, found %s", n.Describe())
+		} else if _, err := strconv.ParseInt(string(n.Value.(json.Number)), 10, 64); err != nil {
+			c.fail(n, path, "expected an integer, found %s", n.Describe())
+		}
+	case reflect.Bool:
+		if n.Kind != Bool {
+			c.fail(n, path, "expected true or false, found %s", n.Describe())
+		}
+	case reflect.Slice:
+		if n.Kind != Array {
+			c.fail(n, path, "expected an array, found %s", n.Describe())
+			return
+		}
+		for i, item := range n.Items {
+			c.check(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Map:
+		if n.Kind != Object {
+			c.fail(n, path, "expected an object, found %s", n.Describe())
+			return
+		}
+		for _, m := range n.Members {
+			c.check(m.Value, t.Elem(), join(path, m.Key))
+		}
+	case reflect.Struct:
+		if n.Kind != Object {
+			c.fail(n, path, "expected an object, found %s", n.Describe())
+			return
+		}
+		c.fields(n, t, path)
+	}
+}
+
+// fields checks the members of an object against the fields of a
+// struct. Keys match field names as in encoding/json: exactly or
+// else ignoring case. Unknown keys other than x- annotations are
+// errors naming the closest field, unless the checker is lenient.
+func (c *typeChecker) fields(n *Node, t reflect.Type, path string) {
+	names := map[string]int{}
+	var order []string
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" && t.Field(i).IsExported() {
+			names[name] = i
+			order = append(order, name)
+		}
+	}
+	for _, m := range n.Members {
+		i, ok := names[m.Key]
+		if !ok {
+			for name, j := range names {
+				if strings.EqualFold(name, m.Key) {
+					i, ok = j, true
+					break
+				}
+			}
+		}
+		switch {
+		case ok:
+			ft := t.Field(i).Type
+			if ot := ds.ProfileOverlay(t, i); ot != nil {
+				ft = ot
+			}
+			c.check(m.Value, ft, join(path, m.Key))
+		case c.strict && !ds.IsExtensionKey(m.Key):
+			c.errs = append(c.errs, newDecodeError(c.data, m.KeyOffset, join(path, m.Key),
+				"unknown key"+suggest.DidYouMean(m.Key, order)))
+		}
+	}
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
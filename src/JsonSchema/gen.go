@@ -0,0 +1,21 @@
+//go:build ignore
+
+// gen writes cmdrx.schema.json from the DataStrucs types.
+package main
+
+import (
+	"log"
+	"os"
+
+	js "go_cmdrX/src/JsonSchema"
+)
+
+func main() {
+	data, err := js.Generate("../DataStrucs")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("cmdrx.schema.json", data, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,221 @@
+package JsonSchema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	ds "go_cmdrX/src/DataStrucs"
+)
+
+// Patterns of the string forms of the typed job fields. A string
+// containing a macro is accepted as it is only parsed after
+// expansion.
+const (
+	macroPattern    = `%\(`
+	unitsPattern    = `^\s*([-+]?(\d+(\.\d*)?|\.\d+)([eE][-+]?\d+)?|-?((\d+(\.\d*)?|\.\d+)(ns|us|µs|ms|s|m|h))+)?\s*$|` + macroPattern
+	intPattern      = `^\s*([-+]?\d+)?\s*$|` + macroPattern
+	dateTimePattern = `^\s*(\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[-+]\d{2}:\d{2})?)?\s*$|` + macroPattern
+)
+
+// typeSchemas are the schemas of types whose JSON form is not their
+// Go structure.
+var typeSchemas = map[reflect.Type]obj{
+	reflect.TypeOf(ds.Minutes{}): {
+		"type": []string{"number", "string"}, "title": "a number of minutes",
+		"pattern": unitsPattern,
+	},
+	reflect.TypeOf(ds.Seconds{}): {
+		"type": []string{"number", "string"}, "title": "a number of seconds",
+		"pattern": unitsPattern,
+	},
+	reflect.TypeOf(ds.OptInt{}): {
+		"type": []string{"integer", "string", "null"}, "title": "an integer",
+		"pattern": intPattern,
+	},
+	reflect.TypeOf(ds.DateTime{}): {
+		"type": []string{"string", "null"}, "title": "a date and time such as \"" + ds.StartDateTimeFormat + "\"",
+		"pattern": dateTimePattern,
+	},
+	reflect.TypeOf(json.RawMessage{}): {},
+}
+
+// stringForms are the struct types which may also be written as a
+// plain string.
+var stringForms = map[reflect.Type]bool{
+	reflect.TypeOf(ds.CmdElement{}): true,
+}
+
+type obj = map[string]interface{}
+
+// Generate builds the command file schema from the DataStrucs types.
+// Property descriptions are taken from the field comments of the Go
+// source in srcDir. Fields tagged jsonschema:"required" are required
+// and jsonschema:"enum=a|b" lists the allowed values. Objects admit
+// no keys but their fields and x- annotations.
+func Generate(srcDir string) ([]byte, error) {
+	docs, err := fieldDocs(srcDir)
+	if err != nil {
+		return nil, err
+	}
+	g := generator{docs: docs, defs: obj{}}
+	root := g.structSchema(reflect.TypeOf(ds.JsonCmdBatch{}))
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["title"] = "CmdrX command file"
+	root["description"] = "A header and the jobs cmdrx runs in order"
+	root["$defs"] = g.defs
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type generator struct {
+	// Field comments by "Type.Field"
+	docs map[string]string
+	defs obj
+}
+
+func (g *generator) schema(t reflect.Type) obj {
+	if s, ok := typeSchemas[t]; ok {
+		c := obj{}
+		for k, v := range s {
+			c[k] = v
+		}
+		return c
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return obj{"type": "string"}
+	case reflect.Int:
+		return obj{"type": "integer"}
+	case reflect.Bool:
+		return obj{"type": "boolean"}
+	case reflect.Slice:
+		return obj{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return obj{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if _, ok := g.defs[t.Name()]; !ok {
+			g.defs[t.Name()] = obj{} // placeholder for recursive types
+			g.defs[t.Name()] = g.structSchema(t)
+		}
+		return obj{"$ref": "#/$defs/" + t.Name()}
+	}
+	panic(fmt.Sprintf("JsonSchema: no schema for %s", t))
+}
+
+func (g *generator) structSchema(t reflect.Type) obj {
+	s := obj{"type": "object", "additionalProperties": false,
+		"patternProperties": obj{"^" + ds.ExtensionPrefix: obj{}}}
+	if stringForms[t] {
+		s["type"] = []string{"object", "string"}
+	}
+	props := obj{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" || !f.IsExported() {
+			continue
+		}
+		p := g.schema(f.Type)
+		if d := g.docs[t.Name()+"."+f.Name]; d != "" {
+			p["description"] = d
+		}
+		for _, opt := range strings.Split(f.Tag.Get("jsonschema"), ",") {
+			switch {
+			case opt == "required":
+				required = append(required, name)
+			case strings.HasPrefix(opt, "enum="):
+				p["enum"] = enumValues(f.Type, strings.TrimPrefix(opt, "enum="))
+			}
+		}
+		props[name] = p
+	}
+	s["properties"] = props
+	if len(required) > 0 {
+		sort.Strings(required)
+		s["required"] = required
+	}
+	return s
+}
+
+func enumValues(t reflect.Type, list string) []interface{} {
+	var values []interface{}
+	for _, v := range strings.Split(list, "|") {
+		if t.Kind() == reflect.Int {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				panic(fmt.Sprintf("JsonSchema: enum value %q of %s is not an integer", v, t))
+			}
+			values = append(values, n)
+			continue
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// fieldDocs reads the comments of the struct fields declared in
+// dir. The continuation indent is dropped and the lines joined.
+func fieldDocs(dir string) (map[string]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	docs := map[string]string{}
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				for _, field := range st.Fields.List {
+					text := docText(field.Doc)
+					if text == "" {
+						text = docText(field.Comment)
+					}
+					for _, n := range field.Names {
+						docs[ts.Name.Name+"."+n.Name] = text
+					}
+				}
+			}
+		}
+	}
+	return docs, nil
+}
+
+func docText(cg *ast.CommentGroup) string {
+	if cg == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(cg.Text()), " ")
+}
@@ -0,0 +1,269 @@
+// Package JsonSchema publishes a JSON Schema of the command file and
+// checks documents against it. The schema is generated from the
+// DataStrucs types, so that editors can offer completion and inline
+// errors; regenerate it with 'go generate' after changing them.
+package JsonSchema
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	jp "go_cmdrX/src/JsonParser"
+	"go_cmdrX/src/stringmgr/suggest"
+)
+
+//go:generate go run gen.go
+
+// Schema is the JSON Schema of the current command file version.
+//
+//go:embed cmdrx.schema.json
+var Schema []byte
+
+// A Problem is a place where a document breaks the schema.
+type Problem struct {
+	// JSON pointer of the offending value
+	Pointer   string
+	Line, Col int
+	Msg       string
+}
+
+func (p Problem) String() string {
+	ptr := p.Pointer
+	if ptr == "" {
+		ptr = "(document)"
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", p.Line, p.Col, ptr, p.Msg)
+}
+
+// schema is the subset of JSON Schema used by Schema.
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 typeList           `json:"type"`
+	Title                string             `json:"title"`
+	Properties           map[string]*schema `json:"properties"`
+	PatternProperties    map[string]*schema `json:"patternProperties"`
+	AdditionalProperties *schema            `json:"additionalProperties"`
+	Required             []string           `json:"required"`
+	Items                *schema            `json:"items"`
+	Enum                 []interface{}      `json:"enum"`
+	Pattern              string             `json:"pattern"`
+	Defs                 map[string]*schema `json:"$defs"`
+
+	// false, as in "additionalProperties": false
+	never   bool
+	pattern *regexp.Regexp
+	// Compiled keys of PatternProperties
+	keyPatterns map[string]*regexp.Regexp
+}
+
+func (s *schema) UnmarshalJSON(data []byte) error {
+	var b bool
+	if json.Unmarshal(data, &b) == nil {
+		s.never = !b
+		return nil
+	}
+	type plain schema
+	if err := json.Unmarshal(data, (*plain)(s)); err != nil {
+		return err
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return err
+		}
+		s.pattern = re
+	}
+	for p := range s.PatternProperties {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return err
+		}
+		if s.keyPatterns == nil {
+			s.keyPatterns = map[string]*regexp.Regexp{}
+		}
+		s.keyPatterns[p] = re
+	}
+	return nil
+}
+
+// patternProperty returns the schema of the first pattern property
+// matching key, or nil.
+func (s *schema) patternProperty(key string) *schema {
+	var patterns []string
+	for p := range s.keyPatterns {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+	for _, p := range patterns {
+		if s.keyPatterns[p].MatchString(key) {
+			return s.PatternProperties[p]
+		}
+	}
+	return nil
+}
+
+// typeList is a "type" written as one name or a list of names.
+type typeList []string
+
+func (t *typeList) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		*t = typeList{s}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// Validate checks a JSON document against Schema. A document which
+// is not JSON is an error; otherwise every problem found is
+// returned, in document order.
+func Validate(data []byte) ([]Problem, error) {
+	var root schema
+	if err := json.Unmarshal(Schema, &root); err != nil {
+		return nil, fmt.Errorf("embedded schema: %v", err)
+	}
+	n, err := jp.ParseNode(data)
+	if err != nil {
+		return nil, err
+	}
+	v := validator{data: data, root: &root}
+	v.check(&root, n, "")
+	sort.SliceStable(v.problems, func(i, j int) bool {
+		a, b := v.problems[i], v.problems[j]
+		return a.Line < b.Line || a.Line == b.Line && a.Col < b.Col
+	})
+	return v.problems, nil
+}
+
+type validator struct {
+	data     []byte
+	root     *schema
+	problems []Problem
+}
+
+func (v *validator) fail(offset int, ptr, format string, a ...interface{}) {
+	line, col := jp.Position(v.data, offset)
+	v.problems = append(v.problems, Problem{ptr, line, col, fmt.Sprintf(format, a...)})
+}
+
+func (v *validator) check(s *schema, n *jp.Node, ptr string) {
+	if s.never {
+		v.fail(n.Offset, ptr, "not allowed")
+		return
+	}
+	if s.Ref != "" {
+		def, ok := v.root.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+		if !ok {
+			v.fail(n.Offset, ptr, "schema reference %q not found", s.Ref)
+			return
+		}
+		v.check(def, n, ptr)
+	}
+	if len(s.Type) > 0 && !hasType(s.Type, n) {
+		want := strings.Join(s.Type, " or ")
+		if s.Title != "" {
+			want = s.Title
+		}
+		v.fail(n.Offset, ptr, "expected %s, found %s", want, n.Describe())
+		return
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, n) {
+		var allowed []string
+		for _, e := range s.Enum {
+			b, _ := json.Marshal(e)
+			allowed = append(allowed, string(b))
+		}
+		v.fail(n.Offset, ptr, "%s is not one of %s", n.Describe(), strings.Join(allowed, ", "))
+	}
+	switch n.Kind {
+	case jp.String:
+		if s.pattern != nil && !s.pattern.MatchString(n.Value.(string)) {
+			if s.Title != "" {
+				v.fail(n.Offset, ptr, "%q is not %s", n.Value, s.Title)
+			} else {
+				v.fail(n.Offset, ptr, "%q does not match the pattern %s", n.Value, s.Pattern)
+			}
+		}
+	case jp.Array:
+		if s.Items != nil {
+			for i, item := range n.Items {
+				v.check(s.Items, item, ptr+"/"+strconv.Itoa(i))
+			}
+		}
+	case jp.Object:
+		seen := map[string]bool{}
+		for _, m := range n.Members {
+			seen[m.Key] = true
+			p := ptr + "/" + escapePointer(m.Key)
+			pp := s.patternProperty(m.Key)
+			switch prop, ok := s.Properties[m.Key]; {
+			case ok:
+				v.check(prop, m.Value, p)
+			case pp != nil:
+				v.check(pp, m.Value, p)
+			case s.AdditionalProperties == nil:
+			case s.AdditionalProperties.never:
+				var names []string
+				for name := range s.Properties {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				v.fail(m.KeyOffset, p, "unknown key %q%s", m.Key, suggest.DidYouMean(m.Key, names))
+			default:
+				v.check(s.AdditionalProperties, m.Value, p)
+			}
+		}
+		for _, r := range s.Required {
+			if !seen[r] {
+				v.fail(n.Offset, ptr, "missing required key %q", r)
+			}
+		}
+	}
+}
+
+func hasType(types []string, n *jp.Node) bool {
+	for _, t := range types {
+		switch {
+		case t == n.Kind.String():
+		case t == "integer" && n.Kind == jp.Number:
+			f, err := n.Value.(json.Number).Float64()
+			if err != nil || f != math.Trunc(f) {
+				continue
+			}
+		default:
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func inEnum(enum []interface{}, n *jp.Node) bool {
+	for _, e := range enum {
+		switch e := e.(type) {
+		case string:
+			if n.Kind == jp.String && n.Value == e {
+				return true
+			}
+		case float64:
+			if n.Kind != jp.Number {
+				continue
+			}
+			if f, err := n.Value.(json.Number).Float64(); err == nil && f == e {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// escapePointer escapes a key as a JSON pointer reference token.
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
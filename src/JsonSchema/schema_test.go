@@ -0,0 +1,73 @@
+package JsonSchema
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSchemaUpToDate(t *testing.T) {
+	t.Log("Given the DataStrucs types:")
+	{
+		data, err := Generate("../DataStrucs")
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		if !bytes.Equal(data, Schema) {
+			t.Errorf("cmdrx.schema.json is out of date; run go generate in src/JsonSchema")
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Log("Given the shipped command file:")
+	{
+		data, err := os.ReadFile("../cmdrX/CmdrX_Cmds.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		problems, err := Validate(bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF")))
+		if err != nil || len(problems) != 0 {
+			t.Errorf("Expected a valid file, got %v %v", problems, err)
+		}
+	}
+
+	t.Log("Given a file with several problems:")
+	{
+		doc := `{"commands_batch": {
+  "jobs_header": {"schedule_catch_up": "some"},
+  "command_jobs": [
+    {"cmd_type": "Console", "cmd_timeout_in_minutes": "fifteen", "cmd_elements": ["ls"]},
+    {"cmd_idle_timeout_in_minutes": "%(IDLE)%", "delay_cmd_start_seconds": "1m30s",
+     "kill_jobs_on_exit_code_less_than": "", "cmd_elements": [{"cmdelement": "ls", "id": 1}]},
+    {"cmd_elemnts": [], "x-note": {"any": "thing"}}
+  ]}}`
+		problems, err := Validate([]byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, p := range problems {
+			got = append(got, p.String())
+		}
+		want := []string{
+			`2:40: /commands_batch/jobs_header/schedule_catch_up: "some" is not one of "", "none", "once", "all"`,
+			`4:55: /commands_batch/command_jobs/0/cmd_timeout_in_minutes: "fifteen" is not a number of minutes`,
+			`6:84: /commands_batch/command_jobs/1/cmd_elements/0/id: unknown key "id"`,
+			`7:5: /commands_batch/command_jobs/2: missing required key "cmd_elements"`,
+			`7:6: /commands_batch/command_jobs/2/cmd_elemnts: unknown key "cmd_elemnts"; did you mean "cmd_elements"?`,
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("Expected problems:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+		}
+	}
+
+	t.Log("Given a file which is not JSON:")
+	{
+		_, err := Validate([]byte("{\n  \"commands_batch\": {,}\n}"))
+		if err == nil || !strings.Contains(err.Error(), "line 2, column 22") {
+			t.Errorf("Expected a positioned syntax error, got %v", err)
+		}
+	}
+}
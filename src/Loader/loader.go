@@ -0,0 +1,220 @@
+// Package Loader reads command files of any registered format into
+// the batch model of package DataStructs.
+//
+// A file's format is chosen by its extension. Files with an unknown
+// or no extension are identified by sniffing their first bytes.
+// JSON, YAML, TOML and the XML schema of the older CmdrX tool are
+// registered by default; other formats are added with Register.
+package Loader
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	ds "go_cmdrX/src/DataStrucs"
+	eu "go_cmdrX/src/ErrUtil"
+	jp "go_cmdrX/src/JsonParser"
+	tp "go_cmdrX/src/TomlParser"
+	xp "go_cmdrX/src/XmlParser"
+	yp "go_cmdrX/src/YamlParser"
+)
+
+// Loader decodes one command file format.
+type Loader interface {
+	// Name identifies the format, e.g. "json".
+	Name() string
+	// Extensions lists the file extensions of the format, with
+	// the leading dot, e.g. ".json".
+	Extensions() []string
+	// Sniff reports whether head, the start of a file with any
+	// byte order mark and leading white space removed, looks like
+	// this format.
+	Sniff(head []byte) bool
+	// Decode reads a whole command file. Unless opts are lenient,
+	// keys matching no setting are errors, except those starting
+	// with ds.ExtensionPrefix.
+	Decode(r io.Reader, opts ds.DecodeOptions) (ds.JsonCmdBatch, error)
+}
+
+// How much of a file is passed to Sniff.
+const sniffLen = 512
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+var (
+	mu      sync.RWMutex
+	loaders []Loader
+)
+
+func init() {
+	Register(jsonLoader{})
+	Register(xmlLoader{})
+	Register(yamlLoader{})
+	Register(tomlLoader{})
+}
+
+// Register adds a format. A format registered under an existing
+// name replaces it; extensions claimed by an earlier format are
+// taken over by the later one.
+func Register(l Loader) {
+	mu.Lock()
+	defer mu.Unlock()
+	for i, old := range loaders {
+		if old.Name() == l.Name() {
+			loaders = append(loaders[:i], loaders[i+1:]...)
+			break
+		}
+	}
+	loaders = append(loaders, l)
+}
+
+// Formats returns the registered formats sorted by name.
+func Formats() []Loader {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := append([]Loader(nil), loaders...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+	return out
+}
+
+// Lookup returns the format registered under name, or nil.
+func Lookup(name string) Loader {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, l := range loaders {
+		if strings.EqualFold(l.Name(), name) {
+			return l
+		}
+	}
+	return nil
+}
+
+// Extensions returns the extensions of every registered format.
+func Extensions() []string {
+	var exts []string
+	for _, l := range Formats() {
+		exts = append(exts, l.Extensions()...)
+	}
+	return exts
+}
+
+// Detect returns the format of a file named fileName which begins
+// with head: the latest format claiming its extension or, failing
+// that, the latest format whose Sniff accepts head.
+func Detect(fileName string, head []byte) (Loader, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	ext := filepath.Ext(fileName)
+	for i := len(loaders) - 1; i >= 0 && ext != ""; i-- {
+		for _, e := range loaders[i].Extensions() {
+			if strings.EqualFold(e, ext) {
+				return loaders[i], nil
+			}
+		}
+	}
+	head = bytes.TrimLeft(bytes.TrimPrefix(head, utf8BOM), " \t\r\n")
+	for i := len(loaders) - 1; i >= 0; i-- {
+		if loaders[i].Sniff(head) {
+			return loaders[i], nil
+		}
+	}
+	return nil, eu.FileError(eu.Parse, fileName, errors.New("unknown command file format"))
+}
+
+// Load reads a command file in its detected format, recording the
+// hash of the content decoded in the batch's SourceSHA256. Errors
+// are *ErrUtil.Error values of kind FileNotFound or Parse.
+func Load(fileName string, opts ds.DecodeOptions) (ds.JsonCmdBatch, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return ds.JsonCmdBatch{}, eu.ReadError(err)
+	}
+	head := data
+	if len(head) > sniffLen {
+		head = head[:sniffLen]
+	}
+	l, err := Detect(fileName, head)
+	if err != nil {
+		return ds.JsonCmdBatch{}, err
+	}
+	batch, err := l.Decode(bytes.NewReader(bytes.TrimPrefix(data, utf8BOM)), opts)
+	if err != nil {
+		return batch, eu.FileError(eu.Parse, fileName, fmt.Errorf("%s: %w", l.Name(), err))
+	}
+	sum := sha256.Sum256(data)
+	batch.SourceSHA256 = hex.EncodeToString(sum[:])
+	return batch, checkSchemaVersion(fileName, &batch)
+}
+
+// checkSchemaVersion rejects batches declaring a schema version
+// other than the current one. Formats with historical versions
+// upgrade them when decoding; the others only have the current one.
+func checkSchemaVersion(fileName string, batch *ds.JsonCmdBatch) error {
+	switch v := batch.SchemaVersion; {
+	case v == 0:
+		batch.SchemaVersion = ds.CurrentSchemaVersion
+	case v > ds.CurrentSchemaVersion:
+		return eu.FileError(eu.Parse, fileName, fmt.Errorf("schema_version %d is newer than this cmdrx supports (%d)",
+			v, ds.CurrentSchemaVersion))
+	case v < ds.CurrentSchemaVersion:
+		return eu.FileError(eu.Parse, fileName, fmt.Errorf("schema_version %d is not supported in this format", v))
+	}
+	return nil
+}
+
+type jsonLoader struct{}
+
+func (jsonLoader) Name() string           { return "json" }
+func (jsonLoader) Extensions() []string   { return []string{".json"} }
+func (jsonLoader) Sniff(head []byte) bool { return len(head) > 0 && head[0] == '{' }
+func (jsonLoader) Decode(r io.Reader, opts ds.DecodeOptions) (ds.JsonCmdBatch, error) {
+	return jp.DecodeJSONCmds(r, opts)
+}
+
+type xmlLoader struct{}
+
+func (xmlLoader) Name() string           { return "xml" }
+func (xmlLoader) Extensions() []string   { return []string{".xml"} }
+func (xmlLoader) Sniff(head []byte) bool { return len(head) > 0 && head[0] == '<' }
+
+// Decode ignores opts: the XML schema of the older tool is fixed and
+// its decoder reads only the elements it knows.
+func (xmlLoader) Decode(r io.Reader, opts ds.DecodeOptions) (ds.JsonCmdBatch, error) {
+	return xp.DecodeXMLCmds(r)
+}
+
+type yamlLoader struct{}
+
+func (yamlLoader) Name() string         { return "yaml" }
+func (yamlLoader) Extensions() []string { return []string{".yaml", ".yml"} }
+func (yamlLoader) Sniff(head []byte) bool {
+	for _, p := range []string{"---", "%YAML", "commands_batch:"} {
+		if bytes.HasPrefix(head, []byte(p)) {
+			return true
+		}
+	}
+	return false
+}
+func (yamlLoader) Decode(r io.Reader, opts ds.DecodeOptions) (ds.JsonCmdBatch, error) {
+	return yp.DecodeYAMLCmds(r, opts)
+}
+
+type tomlLoader struct{}
+
+func (tomlLoader) Name() string         { return "toml" }
+func (tomlLoader) Extensions() []string { return []string{".toml"} }
+func (tomlLoader) Sniff(head []byte) bool {
+	return bytes.HasPrefix(head, []byte("[header]")) || bytes.HasPrefix(head, []byte("[[jobs]]"))
+}
+func (tomlLoader) Decode(r io.Reader, opts ds.DecodeOptions) (ds.JsonCmdBatch, error) {
+	return tp.DecodeTOMLCmds(r, opts)
+}
@@ -0,0 +1,86 @@
+package Loader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ds "go_cmdrX/src/DataStrucs"
+	eu "go_cmdrX/src/ErrUtil"
+)
+
+const jsonFile = `{"commands_batch": {"command_jobs": [{"cmd_display_name": "A"}]}}`
+
+type lineLoader struct{}
+
+func (lineLoader) Name() string           { return "lines" }
+func (lineLoader) Extensions() []string   { return []string{".cmds"} }
+func (lineLoader) Sniff(head []byte) bool { return false }
+func (lineLoader) Decode(r io.Reader, opts ds.DecodeOptions) (ds.JsonCmdBatch, error) {
+	var b ds.JsonCmdBatch
+	data, err := io.ReadAll(r)
+	b.Batch.Jobs = []ds.CmdJob{{DisplayName: string(data)}}
+	return b, err
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		os.WriteFile(p, []byte(content), 0644)
+		return p
+	}
+
+	t.Log("Given files without a known extension:")
+	{
+		for _, p := range []string{
+			write("bom.cfg", "\xEF\xBB\xBF\r\n  "+jsonFile),
+			write("noext", jsonFile),
+			write("old.cmd", "\xEF\xBB\xBF<Commands><ExectuteCommand><CommandDisplayName>A</CommandDisplayName></ExectuteCommand></Commands>"),
+		} {
+			b, err := Load(p, ds.DecodeOptions{})
+			if err != nil || len(b.Batch.Jobs) != 1 || b.Batch.Jobs[0].DisplayName != "A" {
+				t.Errorf("%s: %+v, %v", filepath.Base(p), b, err)
+			}
+		}
+		if _, err := Load(write("plain.txt", "A"), ds.DecodeOptions{}); err == nil {
+			t.Error("Unknown format accepted")
+		}
+	}
+
+	t.Log("Given a JSON file with a BOM and a syntax error:")
+	{
+		b, err := Load(write("c.json", "\xEF\xBB\xBF"+jsonFile), ds.DecodeOptions{})
+		if sum := sha256.Sum256([]byte("\xEF\xBB\xBF" + jsonFile)); err != nil || b.SourceSHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("Hash %q of the content decoded, %v", b.SourceSHA256, err)
+		}
+		_, err = Load(write("bad.json", "{\n  \"commands_batch\": ["), ds.DecodeOptions{})
+		var e *eu.Error
+		if !errors.As(err, &e) || e.Kind != eu.Parse || e.Line != 2 || e.Col != 22 {
+			t.Errorf("Expected a positioned parse error, got %v", err)
+		}
+	}
+
+	t.Log("Given a file which does not exist:")
+	{
+		if _, err := Load(filepath.Join(dir, "none.json"), ds.DecodeOptions{}); !errors.Is(err, eu.FileNotFound) || !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Expected a file not found error, got %v", err)
+		}
+	}
+
+	t.Log("Given a registered format:")
+	{
+		Register(lineLoader{})
+		b, err := Load(write("x.CMDS", "from lines"), ds.DecodeOptions{})
+		if err != nil || b.Batch.Jobs[0].DisplayName != "from lines" {
+			t.Errorf("Custom format not used: %+v, %v", b, err)
+		}
+		if Lookup("LINES") == nil || len(Formats()) != 5 {
+			t.Errorf("Formats %v", Formats())
+		}
+	}
+}
@@ -0,0 +1,302 @@
+// Package Macros expands %(NAME)% macros in command files.
+//
+// A macro is written %(NAME)% or, for macros taking an argument,
+// %(NAME:arg)%, e.g. %(CURDATESTR:2006-01)% or %(ENV:HOME)%. Names
+// are case insensitive. Every batch parameter is available as a
+// macro of the same name. %%( is an escape producing a literal %(.
+// Unknown macros and unterminated %( are errors.
+package Macros
+
+import (
+	"crypto/rand"
+	"encoding"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	ds "go_cmdrX/src/DataStrucs"
+	eu "go_cmdrX/src/ErrUtil"
+)
+
+// Default layouts of the date and time macros.
+const (
+	DefaultDateFormat     = "20060102"
+	DefaultTimeFormat     = "150405"
+	DefaultDateTimeFormat = "20060102_150405"
+)
+
+// Macro describes a built-in macro.
+type Macro struct {
+	Name string
+	// Arg describes the argument, empty for macros taking none.
+	Arg  string
+	Desc string
+	// ArgRequired is set when the macro cannot be used without
+	//   its argument.
+	ArgRequired bool
+	fn          func(c *Context, arg string) (string, error)
+}
+
+var macros = map[string]Macro{}
+
+func register(m Macro) {
+	macros[m.Name] = m
+}
+
+func init() {
+	dateMacro := func(name, desc string, layout func(c *Context) string) {
+		register(Macro{Name: name, Arg: "Go time layout", Desc: desc,
+			fn: func(c *Context, arg string) (string, error) {
+				if arg == "" {
+					arg = layout(c)
+				}
+				return c.Now.Format(arg), nil
+			}})
+	}
+	dateMacro("CURDATESTR", "run start date, macro_date_format or "+DefaultDateFormat,
+		func(c *Context) string { return orDefault(c.Hdr.MacroDateFormat, DefaultDateFormat) })
+	dateMacro("CURTIMESTR", "run start time, macro_time_format or "+DefaultTimeFormat,
+		func(c *Context) string { return orDefault(c.Hdr.MacroTimeFormat, DefaultTimeFormat) })
+	dateMacro("CURDATETIMESTR", "run start date and time, macro_date_time_format or "+DefaultDateTimeFormat,
+		func(c *Context) string { return orDefault(c.Hdr.MacroDateTimeFormat, DefaultDateTimeFormat) })
+
+	register(Macro{Name: "HOSTNAME", Desc: "name of this host",
+		fn: func(c *Context, arg string) (string, error) { return os.Hostname() }})
+	register(Macro{Name: "USER", Desc: "name of the user running cmdrx",
+		fn: func(c *Context, arg string) (string, error) { return currentUser() }})
+	register(Macro{Name: "CMDFILE", Desc: "absolute path of the command file",
+		fn: func(c *Context, arg string) (string, error) { return c.CmdFile, nil }})
+	register(Macro{Name: "CMDFILEDIR", Desc: "directory of the command file",
+		fn: func(c *Context, arg string) (string, error) { return filepath.Dir(c.CmdFile), nil }})
+	register(Macro{Name: "EXEDIR", Desc: "directory of the cmdrx executable",
+		fn: func(c *Context, arg string) (string, error) {
+			exe, err := os.Executable()
+			if err != nil {
+				return "", err
+			}
+			return filepath.Dir(exe), nil
+		}})
+	register(Macro{Name: "RUNID", Desc: "identifier unique to this run",
+		fn: func(c *Context, arg string) (string, error) { return c.RunID, nil }})
+	register(Macro{Name: "SECRET", Arg: "secret name", ArgRequired: true,
+		Desc: "value of a secret declared in jobs_header.secrets; masked in all output",
+		fn: func(c *Context, arg string) (string, error) {
+			if c.Secrets == nil {
+				return "", fmt.Errorf("secret %q: no secrets declared", arg)
+			}
+			return c.Secrets(arg)
+		}})
+	register(Macro{Name: "ENV", Arg: "variable name", ArgRequired: true,
+		Desc: "value of an environment variable; unset variables are an error",
+		fn: func(c *Context, arg string) (string, error) {
+			if v, ok := c.Getenv(arg); ok {
+				return v, nil
+			}
+			return "", fmt.Errorf("environment variable %s is not set", arg)
+		}})
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func currentUser() (string, error) {
+	if u, err := user.Current(); err == nil {
+		return u.Username, nil
+	}
+	for _, v := range []string{"USER", "USERNAME"} {
+		if name := os.Getenv(v); name != "" {
+			return name, nil
+		}
+	}
+	return "", errors.New("cannot determine the current user")
+}
+
+// List returns the built-in macros ordered by name.
+func List() []Macro {
+	var list []Macro
+	for _, m := range macros {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Context holds the values macros expand to. All expansions within
+// a run share the same Now and RunID, so that, e.g., a backup
+// directory created by one job is found by the next.
+type Context struct {
+	Now     time.Time
+	RunID   string
+	CmdFile string
+	// Hdr supplies the macro_*_format layouts.
+	Hdr    ds.CmdHdrDat
+	Getenv func(string) (string, bool)
+	// Params holds the batch parameters keyed by upper case name,
+	//   as returned by ResolveParams.
+	Params map[string]string
+	// Secrets looks up %(SECRET:name)% values.
+	Secrets func(name string) (string, error)
+}
+
+// NewContext returns the context of a run of cmdFile starting now.
+func NewContext(cmdFile string, hdr ds.CmdHdrDat, now time.Time) *Context {
+	if abs, err := filepath.Abs(cmdFile); err == nil {
+		cmdFile = abs
+	}
+	return &Context{Now: now, RunID: NewRunID(now), CmdFile: cmdFile, Hdr: hdr, Getenv: os.LookupEnv}
+}
+
+// NewRunID returns an identifier made of the start time and a
+// random suffix, e.g. "20240131-154500-3f9a".
+func NewRunID(now time.Time) string {
+	b := make([]byte, 2)
+	rand.Read(b)
+	return now.Format("20060102-150405") + "-" + hex.EncodeToString(b)
+}
+
+// Value returns the expansion of one macro.
+func (c *Context) Value(name, arg string) (string, error) {
+	m, ok := macros[strings.ToUpper(name)]
+	if !ok {
+		if v, ok := c.Params[strings.ToUpper(name)]; ok && arg == "" {
+			return v, nil
+		}
+		return "", fmt.Errorf("unknown macro %%(%s)%%", name)
+	}
+	if m.ArgRequired && arg == "" {
+		return "", fmt.Errorf("macro %%(%s)%% needs an argument: %%(%s:%s)%%", name, m.Name, m.Arg)
+	}
+	if m.Arg == "" && arg != "" {
+		return "", fmt.Errorf("macro %%(%s)%% takes no argument", m.Name)
+	}
+	return m.fn(c, arg)
+}
+
+// Expand replaces the macros in s.
+func (c *Context) Expand(s string) (string, error) {
+	if !strings.Contains(s, "%(") {
+		return s, nil
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "%(")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		if i > 0 && s[i-1] == '%' {
+			// %%( is a literal %(
+			b.WriteString(s[:i-1] + "%(")
+			s = s[i+2:]
+			continue
+		}
+		end := strings.Index(s[i+2:], ")%")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated macro in %q", s[i:])
+		}
+		name, arg, _ := strings.Cut(s[i+2:i+2+end], ":")
+		v, err := c.Value(strings.TrimSpace(name), arg)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(s[:i] + v)
+		s = s[i+2+end+2:]
+	}
+}
+
+// ExpandBatch expands the macros in every string field of the
+// header and the jobs, in place. All problems are returned together,
+// each naming the field it was found in.
+func (c *Context) ExpandBatch(b *ds.JsonCmdBatch) error {
+	return errors.Join(c.ExpandHeader(&b.Batch.Hdr), c.ExpandJobs(b.Batch.Jobs))
+}
+
+// ExpandHeader expands the macros in the header's string fields.
+func (c *Context) ExpandHeader(h *ds.CmdHdrDat) error {
+	var errs []error
+	c.expandValue(reflect.ValueOf(h).Elem(), "jobs_header", &errs)
+	return errors.Join(errs...)
+}
+
+// ExpandJobs expands the macros in the jobs' string fields.
+func (c *Context) ExpandJobs(jobs []ds.CmdJob) error {
+	var errs []error
+	for i := range jobs {
+		var jobErrs []error
+		c.expandValue(reflect.ValueOf(&jobs[i]).Elem(), "", &jobErrs)
+		if len(jobErrs) > 0 {
+			errs = append(errs, eu.JobError(eu.Validation, i+1, jobs[i].DisplayName, errors.Join(jobErrs...)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Context) expandValue(v reflect.Value, path string, errs *[]error) {
+	// Typed fields such as time outs keep their text until it has
+	// been expanded, so a macro may supply the value.
+	if tv, ok := textValue(v); ok {
+		text, _ := tv.MarshalText()
+		if !strings.Contains(string(text), "%(") {
+			return
+		}
+		s, err := c.Expand(string(text))
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %v", path, err))
+			return
+		}
+		tv.UnmarshalText([]byte(s))
+		return
+	}
+	switch v.Kind() {
+	case reflect.String:
+		s, err := c.Expand(v.String())
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %v", path, err))
+			return
+		}
+		v.SetString(s)
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			c.expandValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name == "" {
+				name = t.Field(i).Name
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			c.expandValue(v.Field(i), name, errs)
+		}
+	}
+}
+
+type textMarshalUnmarshaler interface {
+	encoding.TextMarshaler
+	encoding.TextUnmarshaler
+}
+
+func textValue(v reflect.Value) (textMarshalUnmarshaler, bool) {
+	if !v.CanAddr() {
+		return nil, false
+	}
+	tv, ok := v.Addr().Interface().(textMarshalUnmarshaler)
+	return tv, ok
+}
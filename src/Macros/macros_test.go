@@ -0,0 +1,130 @@
+package Macros
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	ds "go_cmdrX/src/DataStrucs"
+)
+
+func testContext() *Context {
+	env := map[string]string{"HOME": "/home/op"}
+	return &Context{
+		Now:     time.Date(2024, 1, 31, 15, 45, 0, 0, time.Local),
+		RunID:   "run-1",
+		CmdFile: "/cfg/cmds.json",
+		Getenv:  func(k string) (string, bool) { v, ok := env[k]; return v, ok },
+	}
+}
+
+func TestExpand(t *testing.T) {
+	c := testContext()
+	for in, want := range map[string]string{
+		`.\%(CURDATESTR)%_AtomConfig`:   `.\20240131_AtomConfig`,
+		"%(curtimestr)%":                "154500",
+		"%(CURDATESTR:2006-01)%/x":      "2024-01/x",
+		"%(CURDATETIMESTR)%":            "20240131_154500",
+		"%(ENV:HOME)%/%(RUNID)%":        "/home/op/run-1",
+		"%(CMDFILEDIR)%":                "/cfg",
+		"100%% done, %%(CURDATESTR)% x": "100%% done, %(CURDATESTR)% x",
+		"%PATH% is not a macro":         "%PATH% is not a macro",
+	} {
+		got, err := c.Expand(in)
+		if err != nil || got != want {
+			t.Errorf("%q: got %q, %v; want %q", in, got, err, want)
+		}
+	}
+
+	c.Hdr.MacroDateFormat = "02.01.2006"
+	if got, _ := c.Expand("%(CURDATESTR)%"); got != "31.01.2024" {
+		t.Errorf("macro_date_format not used: %q", got)
+	}
+
+	for in, want := range map[string]string{
+		"%(NOPE)%":        "unknown macro",
+		"%(CURDATESTR":    "unterminated",
+		"%(ENV)%":         "needs an argument",
+		"%(ENV:MISSING)%": "not set",
+		"%(RUNID:x)%":     "takes no argument",
+	} {
+		if _, err := c.Expand(in); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: got %v, want error containing %q", in, err, want)
+		}
+	}
+}
+
+func TestExpandBatch(t *testing.T) {
+	var b ds.JsonCmdBatch
+	b.Batch.Hdr.LogPathFileName = "logs/%(CURDATESTR)%.log"
+	b.Batch.Jobs = []ds.CmdJob{
+		{DisplayName: "Backup", CmdElements: []ds.CmdElement{{CmdUnit: "mkdir"}, {CmdUnit: "%(CURDATESTR)%_bak"}},
+			Inputs: []string{"%(ENV:HOME)%/*.json"}},
+		{DisplayName: "Bad", ExeDir: "%(BOGUS)%"},
+	}
+	err := testContext().ExpandBatch(&b)
+	if err == nil || !strings.Contains(err.Error(), `Job 2 "Bad": execute_cmd_in_dir: unknown macro %(BOGUS)%`) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if b.Batch.Hdr.LogPathFileName != "logs/20240131.log" ||
+		b.Batch.Jobs[0].CmdElements[1].CmdUnit != "20240131_bak" ||
+		b.Batch.Jobs[0].Inputs[0] != "/home/op/*.json" {
+		t.Errorf("Not expanded: %+v", b)
+	}
+}
+
+func TestResolveParams(t *testing.T) {
+	decls := []ds.CmdParam{
+		{Name: "target", Type: "path", Required: true, Desc: "destination"},
+		{Name: "retries", Type: "int", Default: "3"},
+		{Name: "verbose", Type: "bool", Default: "no"},
+		{Name: "mode", Type: "enum", Values: []string{"full", "diff"}, Default: "diff"},
+		{Name: "label"},
+	}
+
+	t.Log("Given defaults and a required parameter:")
+	{
+		values, err := ResolveParams(decls, map[string]string{"target": "D:/T08/", "Mode": "full"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{"TARGET": "D:/T08", "RETRIES": "3", "VERBOSE": "false", "MODE": "full", "LABEL": ""}
+		if runtime.GOOS == "windows" {
+			want["TARGET"] = `D:\T08`
+		}
+		for k, v := range want {
+			if values[k] != v {
+				t.Errorf("%s: got %q, want %q", k, values[k], v)
+			}
+		}
+		c := testContext()
+		c.Params = values
+		if got, err := c.Expand("copy %(target)%/%(label)%x"); err != nil || got != "copy "+want["TARGET"]+"/x" {
+			t.Errorf("Got %q, %v", got, err)
+		}
+	}
+
+	t.Log("Given bad values:")
+	{
+		_, err := ResolveParams(decls, map[string]string{"retries": "many", "mode": "half", "nope": "1"})
+		for _, want := range []string{"-p nope: no such parameter", `"many" is not an int`,
+			`"half" is not one of full, diff`, `parameter "target" is required`} {
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("Missing %q in %v", want, err)
+			}
+		}
+	}
+
+	t.Log("Given bad declarations:")
+	{
+		_, err := ResolveParams([]ds.CmdParam{{Name: "RunID"}, {Name: "x", Type: "enum"},
+			{Name: "n", Type: "int", Default: "x"}, {Name: "f", Type: "float"}}, nil)
+		for _, want := range []string{"clashes with the built-in macro", "enum without values",
+			"invalid default", `unknown type "float"`} {
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("Missing %q in %v", want, err)
+			}
+		}
+	}
+}
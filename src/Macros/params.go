@@ -0,0 +1,159 @@
+package Macros
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	ds "go_cmdrX/src/DataStrucs"
+)
+
+// Parameter types.
+const (
+	ParamString = "string"
+	ParamInt    = "int"
+	ParamBool   = "bool"
+	ParamPath   = "path"
+	ParamEnum   = "enum"
+)
+
+// ParamType returns the type of a declared parameter, defaulting
+// to string.
+func ParamType(p ds.CmdParam) string {
+	if p.Type == "" {
+		return ParamString
+	}
+	return strings.ToLower(p.Type)
+}
+
+// checkParam validates and normalises a parameter value: bools
+// become "true" or "false" and paths are cleaned and converted to
+// the platform's separators.
+func checkParam(p ds.CmdParam, v string) (string, error) {
+	switch ParamType(p) {
+	case ParamString:
+		return v, nil
+	case ParamInt:
+		if _, err := strconv.Atoi(strings.TrimSpace(v)); err != nil {
+			return "", fmt.Errorf("%q is not an int", v)
+		}
+		return strings.TrimSpace(v), nil
+	case ParamBool:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "yes", "on":
+			return "true", nil
+		case "no", "off":
+			return "false", nil
+		}
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return "", fmt.Errorf("%q is not a bool", v)
+		}
+		return strconv.FormatBool(b), nil
+	case ParamPath:
+		if strings.TrimSpace(v) == "" {
+			return "", errors.New("empty path")
+		}
+		return filepath.Clean(filepath.FromSlash(v)), nil
+	case ParamEnum:
+		for _, allowed := range p.Values {
+			if v == allowed {
+				return v, nil
+			}
+		}
+		return "", fmt.Errorf("%q is not one of %s", v, strings.Join(p.Values, ", "))
+	}
+	return "", fmt.Errorf("unknown type %q", p.Type)
+}
+
+func knownParamType(t string) bool {
+	switch t {
+	case ParamString, ParamInt, ParamBool, ParamPath, ParamEnum:
+		return true
+	}
+	return false
+}
+
+// ResolveParams checks the parameter declarations and the values
+// given on the command line, and returns the value of every
+// parameter. Declared defaults apply to optional parameters which
+// are not set. All problems are returned together.
+func ResolveParams(decls []ds.CmdParam, set map[string]string) (map[string]string, error) {
+	var errs []error
+	values := map[string]string{}
+	declared := map[string]ds.CmdParam{}
+	// reported holds the parameters already in error, or set on
+	//   the command line, which need no "missing" error.
+	reported := map[string]bool{}
+	for _, p := range decls {
+		key := strings.ToUpper(p.Name)
+		switch {
+		case p.Name == "" || strings.ContainsAny(p.Name, ":()% "):
+			errs = append(errs, fmt.Errorf("parameter %q: invalid name", p.Name))
+			continue
+		case declared[key].Name != "":
+			errs = append(errs, fmt.Errorf("parameter %q: declared twice", p.Name))
+			continue
+		case macros[key].Name != "":
+			errs = append(errs, fmt.Errorf("parameter %q: clashes with the built-in macro %%(%s)%%", p.Name, key))
+			continue
+		case !knownParamType(ParamType(p)):
+			errs = append(errs, fmt.Errorf("parameter %q: unknown type %q: use string, int, bool, path or enum", p.Name, p.Type))
+			continue
+		case ParamType(p) == ParamEnum && len(p.Values) == 0:
+			errs = append(errs, fmt.Errorf("parameter %q: enum without values", p.Name))
+			continue
+		}
+		declared[key] = p
+		if p.Required || (p.Default == "" && ParamType(p) != ParamString) {
+			// Required parameters must be set; of the others only
+			//   strings have a natural empty value.
+			continue
+		}
+		v, err := checkParam(p, p.Default)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("parameter %q: invalid default: %v", p.Name, err))
+			reported[key] = true
+			continue
+		}
+		values[key] = v
+	}
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v := set[name]
+		reported[strings.ToUpper(name)] = true
+		p, ok := declared[strings.ToUpper(name)]
+		if !ok {
+			errs = append(errs, fmt.Errorf("-p %s: no such parameter", name))
+			continue
+		}
+		v, err := checkParam(p, v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("-p %s: %v", name, err))
+			continue
+		}
+		values[strings.ToUpper(name)] = v
+	}
+	for _, p := range decls {
+		key := strings.ToUpper(p.Name)
+		if _, ok := declared[key]; !ok {
+			continue
+		}
+		delete(declared, key)
+		if _, ok := values[key]; !ok && !reported[key] {
+			if p.Required {
+				errs = append(errs, fmt.Errorf("parameter %q is required: use -p %s=VALUE", p.Name, p.Name))
+			} else {
+				errs = append(errs, fmt.Errorf("parameter %q has no default: use -p %s=VALUE", p.Name, p.Name))
+			}
+		}
+	}
+	return values, errors.Join(errs...)
+}
@@ -0,0 +1,248 @@
+// Package Profiles applies the named overlays of a command file.
+//
+// A profile is applied in this order, which makes the result
+// independent of map ordering:
+//
+//  1. the profile it extends, recursively, is applied first;
+//  2. its jobs_header overlay is merged into the header;
+//  3. jobs named in remove_jobs are dropped;
+//  4. each command_jobs overlay, in file order, is merged into the
+//     job with the same cmd_display_name, or appended as an extra
+//     job when there is none.
+//
+// Merging follows JSON Merge Patch (RFC 7386): objects merge field
+// by field, arrays and scalars replace the base value and null
+// resets a field to its zero value.
+package Profiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	ds "go_cmdrX/src/DataStrucs"
+)
+
+// Names returns the names of the profiles in order.
+func Names(profiles map[string]ds.CmdProfile) []string {
+	var names []string
+	for n := range profiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Apply overlays the named profile on the batch. An empty name
+// leaves the batch unchanged. The profiles themselves are removed
+// from the result. Overlay keys matching no setting are errors,
+// unless they are x- annotations or opts are lenient.
+func Apply(batch *ds.JsonCmdBatch, name string, opts ds.DecodeOptions) error {
+	profiles := batch.Batch.Profiles
+	batch.Batch.Profiles = nil
+	if name == "" {
+		return nil
+	}
+	if _, ok := profiles[name]; !ok {
+		avail := "none defined"
+		if len(profiles) > 0 {
+			avail = "available: " + strings.Join(Names(profiles), ", ")
+		}
+		return fmt.Errorf("unknown profile %q; %s", name, avail)
+	}
+	var chain []string
+	for n := name; n != ""; n = profiles[n].Extends {
+		for _, seen := range chain {
+			if seen == n {
+				return fmt.Errorf("profile %q: extends cycle %s -> %s", name, strings.Join(chain, " -> "), n)
+			}
+		}
+		if _, ok := profiles[n]; !ok {
+			return fmt.Errorf("profile %q extends unknown profile %q", chain[len(chain)-1], n)
+		}
+		chain = append(chain, n)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		if err := applyOne(&batch.Batch, profiles[chain[i]], opts); err != nil {
+			return fmt.Errorf("profile %q: %v", chain[i], err)
+		}
+	}
+	return nil
+}
+
+func applyOne(b *ds.CmdHdr, p ds.CmdProfile, opts ds.DecodeOptions) error {
+	var errs []error
+	if len(p.Hdr) > 0 {
+		if err := mergeInto(&b.Hdr, p.Hdr, opts); err != nil {
+			errs = append(errs, fmt.Errorf("jobs_header: %v", err))
+		}
+	}
+
+	for _, name := range p.RemoveJobs {
+		idx, err := findJob(b.Jobs, name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("remove_jobs: %v", err))
+			continue
+		}
+		b.Jobs = append(b.Jobs[:idx], b.Jobs[idx+1:]...)
+	}
+
+	for i, raw := range p.Jobs {
+		var key struct {
+			DisplayName string `json:"cmd_display_name"`
+		}
+		if err := json.Unmarshal(raw, &key); err != nil || key.DisplayName == "" {
+			errs = append(errs, fmt.Errorf("command_jobs[%d]: an overlay needs a cmd_display_name", i))
+			continue
+		}
+		idx, err := findJob(b.Jobs, key.DisplayName)
+		if errors.Is(err, errAmbiguous) {
+			errs = append(errs, fmt.Errorf("command_jobs[%d]: %v", i, err))
+			continue
+		}
+		if err != nil {
+			var job ds.CmdJob
+			if err := mergeInto(&job, raw, opts); err != nil {
+				errs = append(errs, fmt.Errorf("command_jobs[%d]: %v", i, err))
+				continue
+			}
+			b.Jobs = append(b.Jobs, job)
+			continue
+		}
+		if err := mergeInto(&b.Jobs[idx], raw, opts); err != nil {
+			errs = append(errs, fmt.Errorf("command_jobs[%d]: %v", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var errAmbiguous = errors.New("several jobs have this name")
+
+// findJob returns the index of the only job named name.
+func findJob(jobs []ds.CmdJob, name string) (int, error) {
+	idx := -1
+	for i, j := range jobs {
+		if j.DisplayName == name {
+			if idx >= 0 {
+				return -1, fmt.Errorf("%q: %w", name, errAmbiguous)
+			}
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return -1, fmt.Errorf("no job named %q", name)
+	}
+	return idx, nil
+}
+
+// mergeInto applies a merge patch to v, a pointer to a struct.
+// Fields in the patch which v does not have are errors, but for x-
+// annotations, which are dropped, and unless opts are lenient.
+func mergeInto(v interface{}, patch json.RawMessage, opts ds.DecodeOptions) error {
+	base, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var doc, p interface{}
+	if err := json.Unmarshal(base, &doc); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return err
+	}
+	if _, ok := p.(map[string]interface{}); !ok {
+		return errors.New("overlay is not an object")
+	}
+	dropExtensions(p, reflect.TypeOf(v).Elem())
+	merged, err := json.Marshal(MergePatch(doc, p))
+	if err != nil {
+		return err
+	}
+	// Decode into a fresh value so that cleared fields take their
+	//   zero values.
+	dec := json.NewDecoder(bytes.NewReader(merged))
+	if !opts.Lenient {
+		dec.DisallowUnknownFields()
+	}
+	fresh := reflect.New(reflect.TypeOf(v).Elem())
+	if err := dec.Decode(fresh.Interface()); err != nil {
+		return err
+	}
+	reflect.ValueOf(v).Elem().Set(fresh.Elem())
+	return nil
+}
+
+// dropExtensions removes the x- annotations from the objects of x
+// which decode as structs, x being decoded as a value of type t.
+func dropExtensions(x interface{}, t reflect.Type) {
+	switch t.Kind() {
+	case reflect.Pointer:
+		dropExtensions(x, t.Elem())
+	case reflect.Slice:
+		items, _ := x.([]interface{})
+		for _, item := range items {
+			dropExtensions(item, t.Elem())
+		}
+	case reflect.Map:
+		m, _ := x.(map[string]interface{})
+		for _, val := range m {
+			dropExtensions(val, t.Elem())
+		}
+	case reflect.Struct:
+		m, _ := x.(map[string]interface{})
+		for k, val := range m {
+			if ds.IsExtensionKey(k) {
+				delete(m, k)
+			} else if f, ok := jsonField(t, k); ok {
+				dropExtensions(val, f.Type)
+			}
+		}
+	}
+}
+
+// jsonField returns the field of struct type t which encoding/json
+// decodes key into: the one named key, or else the one named key
+// ignoring case.
+func jsonField(t reflect.Type, key string) (reflect.StructField, bool) {
+	var fold reflect.StructField
+	found := false
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == key {
+			return f, true
+		}
+		if !found && strings.EqualFold(name, key) {
+			fold, found = f, true
+		}
+	}
+	return fold, found
+}
+
+// MergePatch returns patch applied to doc as defined by RFC 7386.
+func MergePatch(doc, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	d, ok := doc.(map[string]interface{})
+	if !ok {
+		d = map[string]interface{}{}
+	}
+	out := make(map[string]interface{}, len(d))
+	for k, v := range d {
+		out[k] = v
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(out, k)
+			continue
+		}
+		out[k] = MergePatch(out[k], v)
+	}
+	return out
+}
@@ -0,0 +1,121 @@
+package Profiles
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	ds "go_cmdrX/src/DataStrucs"
+)
+
+const testFile = `{"commands_batch": {
+  "jobs_header": {"command_exe_directory": "/base", "log_path_file_name": "base.log"},
+  "command_jobs": [
+    {"cmd_display_name": "A", "cmd_type": "Console", "cmd_timeout_in_minutes": "15",
+     "cmd_elements": [{"cmdelement": "echo"}, {"cmdelement": "a"}]},
+    {"cmd_display_name": "B", "cmd_type": "Console",
+     "cmd_elements": [{"cmdelement": "echo"}, {"cmdelement": "b"}]}
+  ],
+  "profiles": {
+    "staging": {"jobs_header": {"command_exe_directory": "/staging", "log_path_file_name": null},
+                "command_jobs": [{"cmd_display_name": "A", "cmd_timeout_in_minutes": "30"}]},
+    "prod": {"extends": "staging", "remove_jobs": ["B"],
+             "command_jobs": [{"cmd_display_name": "C", "cmd_elements": [{"cmdelement": "c"}]}]},
+    "loop1": {"extends": "loop2"},
+    "loop2": {"extends": "loop1"},
+    "orphan": {"extends": "missing"},
+    "typo": {"command_jobs": [{"cmd_display_name": "A", "cmd_timeout": "1"}]},
+    "annotated": {"jobs_header": {"x-owner": "ops"},
+                  "command_jobs": [{"cmd_display_name": "A", "x-ticket": "OPS-1",
+                                    "cmd_elements": [{"cmdelement": "echo", "x-note": "n"}]}]}
+  }}}`
+
+func load(t *testing.T) ds.JsonCmdBatch {
+	var b ds.JsonCmdBatch
+	if err := json.Unmarshal([]byte(testFile), &b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestApply(t *testing.T) {
+	t.Log("Given a profile extending another:")
+	{
+		b := load(t)
+		if err := Apply(&b, "prod", ds.DecodeOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		h := b.Batch.Hdr
+		if h.CmdExeDirectory != "/staging" || h.LogPathFileName != "" {
+			t.Errorf("Header not merged: dir %q, log %q", h.CmdExeDirectory, h.LogPathFileName)
+		}
+		var names []string
+		for _, j := range b.Batch.Jobs {
+			names = append(names, j.DisplayName)
+		}
+		if strings.Join(names, ",") != "A,C" {
+			t.Errorf("Jobs %v, want A,C", names)
+		}
+		if a := b.Batch.Jobs[0]; a.TimeOutMinutes.Duration != 30*time.Minute || len(a.CmdElements) != 2 {
+			t.Errorf("Job A not merged: %+v", a)
+		}
+		if b.Batch.Profiles != nil {
+			t.Error("Profiles kept in the result")
+		}
+	}
+
+	t.Log("Given no profile:")
+	{
+		b := load(t)
+		if err := Apply(&b, "", ds.DecodeOptions{}); err != nil || len(b.Batch.Jobs) != 2 || b.Batch.Hdr.CmdExeDirectory != "/base" {
+			t.Errorf("Batch changed: %v", err)
+		}
+	}
+
+	t.Log("Given broken profiles:")
+	{
+		for name, want := range map[string]string{
+			"nope":   "unknown profile",
+			"loop1":  "extends cycle",
+			"orphan": `extends unknown profile "missing"`,
+			"typo":   "unknown field",
+		} {
+			b := load(t)
+			if err := Apply(&b, name, ds.DecodeOptions{}); err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: got %v, want %q", name, err, want)
+			}
+		}
+	}
+
+	t.Log("Given x- annotations and a lenient unknown key:")
+	{
+		b := load(t)
+		if err := Apply(&b, "annotated", ds.DecodeOptions{}); err != nil || len(b.Batch.Jobs[0].CmdElements) != 1 {
+			t.Errorf("Annotated profile: %v", err)
+		}
+		b = load(t)
+		if err := Apply(&b, "typo", ds.DecodeOptions{Lenient: true}); err != nil {
+			t.Errorf("Lenient profile: %v", err)
+		}
+	}
+
+	t.Log("Given duplicate job names:")
+	{
+		b := load(t)
+		b.Batch.Jobs[1].DisplayName = "A"
+		if err := Apply(&b, "staging", ds.DecodeOptions{}); err == nil || !strings.Contains(err.Error(), "several jobs") {
+			t.Errorf("Ambiguous overlay accepted: %v", err)
+		}
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	var doc, patch interface{}
+	json.Unmarshal([]byte(`{"a": 1, "b": {"c": 2, "d": 3}, "e": [1, 2]}`), &doc)
+	json.Unmarshal([]byte(`{"a": null, "b": {"d": 4}, "e": [3]}`), &patch)
+	got, _ := json.Marshal(MergePatch(doc, patch))
+	if want := `{"b":{"c":2,"d":4},"e":[3]}`; string(got) != want {
+		t.Errorf("Got %s, want %s", got, want)
+	}
+}
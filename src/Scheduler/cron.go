@@ -0,0 +1,183 @@
+package Scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule returns the next activation time strictly after t.
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// Interval is a fixed period schedule, written "@every <duration>".
+type Interval time.Duration
+
+// Next returns t plus the interval.
+func (i Interval) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(i))
+}
+
+// CronSchedule is a classic five field cron expression:
+// minute hour day-of-month month day-of-week.
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// Day of month and day of week are OR'ed when both are
+	//   restricted, as in cron.
+	domStar, dowStar bool
+}
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+var dayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseSchedule parses a cron expression, a descriptor such as
+// "@daily", or an interval such as "@every 90m".
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(spec[len("@every "):]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %v", spec, err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("invalid schedule %q: interval must be at least 1s", spec)
+		}
+		return Interval(d), nil
+	}
+	if d, ok := descriptors[spec]; ok {
+		spec = d
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields, found %d", spec, len(fields))
+	}
+	var c CronSchedule
+	var err error
+	parse := func(field string, min, max int, names []string, nameBase int) uint64 {
+		if err != nil {
+			return 0
+		}
+		var bits uint64
+		bits, err = parseField(field, min, max, names, nameBase)
+		if err != nil {
+			err = fmt.Errorf("invalid schedule %q: %v", spec, err)
+		}
+		return bits
+	}
+	c.minute = parse(fields[0], 0, 59, nil, 0)
+	c.hour = parse(fields[1], 0, 23, nil, 0)
+	c.dom = parse(fields[2], 1, 31, nil, 0)
+	c.month = parse(fields[3], 1, 12, monthNames, 1)
+	c.dow = parse(fields[4], 0, 7, dayNames, 0)
+	if err != nil {
+		return nil, err
+	}
+	// 7 is an alias for Sunday.
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domStar = fields[2] == "*" || fields[2] == "?"
+	c.dowStar = fields[4] == "*" || fields[4] == "?"
+	return c, nil
+}
+
+// parseField parses a comma separated list of values, ranges and
+// steps into a bit set.
+func parseField(field string, min, max int, names []string, nameBase int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			rng, step = part[:i], s
+		}
+		lo, hi := min, max
+		if rng != "*" && rng != "?" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = fieldValue(bounds[0], names, nameBase); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = fieldValue(bounds[1], names, nameBase); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func fieldValue(s string, names []string, nameBase int) (int, error) {
+	for i, n := range names {
+		if strings.EqualFold(s, n) {
+			return i + nameBase, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("bad value %q", s)
+	}
+	return v, nil
+}
+
+func (c CronSchedule) dayMatches(t time.Time) bool {
+	domOK := c.dom&(1<<uint(t.Day())) != 0
+	dowOK := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
+
+// Next returns the first matching minute strictly after t, in t's
+// location. The zero time is returned if nothing matches within
+// five years, e.g. for "0 0 30 2 *".
+func (c CronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,52 @@
+package Scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	base := time.Date(2026, 10, 16, 14, 7, 30, 0, time.UTC) // Friday
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 10, 16, 14, 15, 0, 0, time.UTC)},
+		{"30 2 * * 1-5", time.Date(2026, 10, 19, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 12 13 * fri", time.Date(2026, 10, 23, 12, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)},
+		{"@every 90m", base.Add(90 * time.Minute)},
+	}
+	for _, tc := range tests {
+		s, err := ParseSchedule(tc.spec)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q): %v", tc.spec, err)
+			continue
+		}
+		if got := s.Next(base); !got.Equal(tc.want) {
+			t.Errorf("%q: Next = %v, want %v", tc.spec, got, tc.want)
+		}
+	}
+}
+
+func TestCronRejectsBadSpecs(t *testing.T) {
+	for _, spec := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "@every x"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+func TestCatchUpPolicies(t *testing.T) {
+	s, _ := ParseSchedule("0 * * * *")
+	now := time.Date(2026, 10, 16, 14, 30, 0, 0, time.UTC)
+	e := &Entry{Schedule: s, LastRun: now.Add(-5 * time.Hour)}
+	for policy, want := range map[string]int{CatchUpNone: 0, CatchUpOnce: 1, CatchUpAll: 5} {
+		e.CatchUp = policy
+		if got := e.CatchUpRuns(now); got != want {
+			t.Errorf("Policy %q: %d runs, want %d", policy, got, want)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package Scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Overlap policies, applied when a batch is due while its previous
+// run is still in progress.
+const (
+	OverlapSkip  = "skip"
+	OverlapQueue = "queue"
+)
+
+// Catch up policies, applied at start up to runs missed while the
+// daemon was down.
+const (
+	CatchUpNone = "none"
+	CatchUpOnce = "once"
+	CatchUpAll  = "all"
+)
+
+// MaxCatchUpRuns bounds the runs replayed by the "all" policy.
+const MaxCatchUpRuns = 100
+
+// RunFunc runs the batch in a command file.
+type RunFunc func(ctx context.Context, cmdFile string) error
+
+// Entry is one scheduled command file.
+type Entry struct {
+	CmdFile   string
+	Schedule  Schedule
+	Overlap   string
+	CatchUp   string
+	StatePath string
+
+	// LastRun is the scheduled time of the most recent run.
+	LastRun time.Time
+	next    time.Time
+	running bool
+	// Scheduled times of the runs waiting for the current one
+	queue []time.Time
+}
+
+// entryState is persisted in an entry's StatePath.
+type entryState struct {
+	CmdFile string    `json:"cmd_file"`
+	LastRun time.Time `json:"last_run"`
+}
+
+// NewEntry validates the policies of an entry and loads its last
+// run time from statePath.
+func NewEntry(cmdFile, spec, overlap, catchUp, statePath string) (*Entry, error) {
+	sched, err := ParseSchedule(spec)
+	if err != nil {
+		return nil, err
+	}
+	if overlap == "" {
+		overlap = OverlapSkip
+	}
+	if overlap != OverlapSkip && overlap != OverlapQueue {
+		return nil, fmt.Errorf("invalid schedule_overlap %q", overlap)
+	}
+	if catchUp == "" {
+		catchUp = CatchUpNone
+	}
+	if catchUp != CatchUpNone && catchUp != CatchUpOnce && catchUp != CatchUpAll {
+		return nil, fmt.Errorf("invalid schedule_catch_up %q", catchUp)
+	}
+	e := &Entry{CmdFile: cmdFile, Schedule: sched, Overlap: overlap, CatchUp: catchUp, StatePath: statePath}
+	if b, err := os.ReadFile(statePath); err == nil {
+		var st entryState
+		if err := json.Unmarshal(b, &st); err != nil {
+			return nil, fmt.Errorf("Schedule State Error: %s: %v", statePath, err)
+		}
+		e.LastRun = st.LastRun
+	}
+	return e, nil
+}
+
+func (e *Entry) saveState() error {
+	b, err := json.MarshalIndent(entryState{CmdFile: e.CmdFile, LastRun: e.LastRun}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := e.StatePath + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, e.StatePath)
+}
+
+// MissedRuns returns the scheduled times after LastRun and up to
+// now, at most limit of them. An entry which never ran has missed
+// nothing.
+func (e *Entry) MissedRuns(now time.Time, limit int) []time.Time {
+	var missed []time.Time
+	if e.LastRun.IsZero() {
+		return nil
+	}
+	for t := e.Schedule.Next(e.LastRun); !t.IsZero() && !t.After(now); t = e.Schedule.Next(t) {
+		if len(missed) == limit {
+			break
+		}
+		missed = append(missed, t)
+	}
+	return missed
+}
+
+// CatchUpRuns returns how many missed runs the entry's catch up
+// policy replays at start up.
+func (e *Entry) CatchUpRuns(now time.Time) int {
+	return len(e.catchUp(now))
+}
+
+// catchUp returns the scheduled times of the runs replayed at start
+// up: each missed run for "all", and for "once" a single run at now
+// standing for all of them.
+func (e *Entry) catchUp(now time.Time) []time.Time {
+	switch e.CatchUp {
+	case CatchUpOnce:
+		if len(e.MissedRuns(now, 1)) > 0 {
+			return []time.Time{now}
+		}
+	case CatchUpAll:
+		return e.MissedRuns(now, MaxCatchUpRuns)
+	}
+	return nil
+}
+
+// Daemon runs command files on their schedules until its context
+// is cancelled.
+type Daemon struct {
+	Entries []*Entry
+	Run     RunFunc
+	Log     io.Writer
+	// Now and After replace time.Now and time.After when set, so
+	//   that tests can drive the schedule.
+	Now   func() time.Time
+	After func(time.Duration) <-chan time.Time
+}
+
+type runDone struct {
+	entry *Entry
+	err   error
+}
+
+// Loop schedules the entries. Runs of different entries may overlap;
+// runs of the same entry follow the entry's overlap policy. An
+// entry's LastRun is saved as each of its runs starts, so that runs
+// still queued when the daemon stops are caught up after a restart.
+func (d *Daemon) Loop(ctx context.Context) error {
+	done := make(chan runDone)
+	active := 0
+	start := func(e *Entry, scheduled time.Time) {
+		e.running = true
+		e.LastRun = scheduled
+		if err := e.saveState(); err != nil {
+			d.logf("%s: cannot save schedule state: %v\n", e.CmdFile, err)
+		}
+		d.logf("%s: starting run scheduled for %s\n", e.CmdFile, scheduled.Format(time.RFC3339))
+		active++
+		go func() {
+			err := d.Run(ctx, e.CmdFile)
+			done <- runDone{e, err}
+		}()
+	}
+	startQueued := func(e *Entry) {
+		scheduled := e.queue[0]
+		e.queue = e.queue[1:]
+		start(e, scheduled)
+	}
+
+	now := d.now()
+	for _, e := range d.Entries {
+		if e.LastRun.IsZero() {
+			// Record a baseline so that runs missed from now on
+			//   can be caught up after a restart.
+			e.LastRun = now
+			if err := e.saveState(); err != nil {
+				d.logf("%s: cannot save schedule state: %v\n", e.CmdFile, err)
+			}
+		}
+		e.queue = e.catchUp(now)
+		if len(e.queue) > 0 {
+			d.logf("%s: catching up %d missed run(s)\n", e.CmdFile, len(e.queue))
+			startQueued(e)
+		}
+		e.next = e.Schedule.Next(now)
+		d.logf("%s: next run %s\n", e.CmdFile, e.next.Format(time.RFC3339))
+	}
+
+	for {
+		var wake <-chan time.Time
+		if first := d.nextDue(); !first.IsZero() {
+			wake = d.after(first.Sub(d.now()))
+		}
+		select {
+		case <-ctx.Done():
+			for ; active > 0; active-- {
+				<-done
+			}
+			return ctx.Err()
+		case r := <-done:
+			active--
+			r.entry.running = false
+			if r.err != nil {
+				d.logf("%s: run failed: %v\n", r.entry.CmdFile, r.err)
+			} else {
+				d.logf("%s: run succeeded\n", r.entry.CmdFile)
+			}
+			if len(r.entry.queue) > 0 {
+				startQueued(r.entry)
+			}
+		case <-wake:
+			now := d.now()
+			for _, e := range d.Entries {
+				if e.next.IsZero() || e.next.After(now) {
+					continue
+				}
+				due := e.next
+				e.next = e.Schedule.Next(now)
+				switch {
+				case !e.running:
+					start(e, due)
+				case e.Overlap == OverlapQueue:
+					e.queue = append(e.queue, due)
+					d.logf("%s: previous run still active; run queued\n", e.CmdFile)
+				default:
+					d.logf("%s: previous run still active; run skipped\n", e.CmdFile)
+				}
+			}
+		}
+	}
+}
+
+func (d *Daemon) now() time.Time {
+	if d.Now != nil {
+		return d.Now()
+	}
+	return time.Now()
+}
+
+func (d *Daemon) after(wait time.Duration) <-chan time.Time {
+	if d.After != nil {
+		return d.After(wait)
+	}
+	return time.After(wait)
+}
+
+// nextDue returns the earliest next run time of all entries.
+func (d *Daemon) nextDue() time.Time {
+	var first time.Time
+	for _, e := range d.Entries {
+		if !e.next.IsZero() && (first.IsZero() || e.next.Before(first)) {
+			first = e.next
+		}
+	}
+	return first
+}
+
+func (d *Daemon) logf(format string, a ...interface{}) {
+	if d.Log != nil {
+		fmt.Fprint(d.Log, d.now().Format("2006-01-02 15:04:05 "))
+		fmt.Fprintf(d.Log, format, a...)
+	}
+}
@@ -0,0 +1,199 @@
+package Scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock hands the test the timer of every wait of the daemon,
+// so that the test knows when the daemon is idle and decides when
+// it wakes.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits chan chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(time.Duration) <-chan time.Time {
+	w := make(chan time.Time)
+	c.waits <- w
+	return w
+}
+
+// daemonTest runs a daemon with one entry scheduled every minute,
+// whose runs last until released.
+type daemonTest struct {
+	t       *testing.T
+	entry   *Entry
+	clock   *fakeClock
+	log     bytes.Buffer
+	started chan struct{}
+	release chan struct{}
+	cancel  context.CancelFunc
+	errc    chan error
+}
+
+var daemonStart = time.Date(2026, 10, 16, 10, 0, 30, 0, time.UTC)
+
+func startDaemon(t *testing.T, overlap, catchUp string, lastRun time.Time) *daemonTest {
+	s, _ := ParseSchedule("* * * * *")
+	dt := &daemonTest{t: t,
+		entry: &Entry{CmdFile: "batch.json", Schedule: s, Overlap: overlap, CatchUp: catchUp,
+			StatePath: filepath.Join(t.TempDir(), "state.json"), LastRun: lastRun},
+		clock:   &fakeClock{now: daemonStart, waits: make(chan chan time.Time, 1)},
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+		errc:    make(chan error, 1),
+	}
+	d := &Daemon{Entries: []*Entry{dt.entry}, Log: &dt.log, Now: dt.clock.Now, After: dt.clock.After,
+		Run: func(ctx context.Context, cmdFile string) error {
+			select {
+			case dt.started <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			select {
+			case <-dt.release:
+			case <-ctx.Done():
+			}
+			return nil
+		}}
+	var ctx context.Context
+	ctx, dt.cancel = context.WithCancel(context.Background())
+	go func() { dt.errc <- d.Loop(ctx) }()
+	return dt
+}
+
+// waiting returns the timer of the daemon's next wait, once it has
+// handled everything before.
+func (dt *daemonTest) waiting() chan time.Time {
+	dt.t.Helper()
+	select {
+	case w := <-dt.clock.waits:
+		return w
+	case <-time.After(5 * time.Second):
+		dt.t.Fatal("Daemon not waiting")
+		return nil
+	}
+}
+
+// wake sets the time and ends the daemon's wait w.
+func (dt *daemonTest) wake(w chan time.Time, to time.Time) {
+	dt.clock.mu.Lock()
+	dt.clock.now = to
+	dt.clock.mu.Unlock()
+	w <- to
+}
+
+// expectRun waits for a run to start and checks the last run saved
+// when it did.
+func (dt *daemonTest) expectRun(scheduled time.Time) {
+	dt.t.Helper()
+	select {
+	case <-dt.started:
+	case <-time.After(5 * time.Second):
+		dt.t.Fatalf("No run started for %s", scheduled.Format(time.TimeOnly))
+	}
+	b, err := os.ReadFile(dt.entry.StatePath)
+	if err != nil {
+		dt.t.Fatal(err)
+	}
+	var st entryState
+	json.Unmarshal(b, &st)
+	if !st.LastRun.Equal(scheduled) {
+		dt.t.Errorf("Saved last run %s, expected %s", st.LastRun.Format(time.TimeOnly), scheduled.Format(time.TimeOnly))
+	}
+}
+
+// stop ends the daemon and returns its log.
+func (dt *daemonTest) stop() string {
+	dt.cancel()
+	<-dt.errc
+	select {
+	case <-dt.started:
+		dt.t.Error("Unexpected run")
+	default:
+	}
+	return dt.log.String()
+}
+
+func at(hhmm string) time.Time {
+	t, _ := time.Parse(time.TimeOnly, hhmm+":00")
+	return time.Date(2026, 10, 16, t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
+
+func TestDaemonOverlap(t *testing.T) {
+	t.Log("Given a run due while the previous one is active, and overlap skip:")
+	{
+		dt := startDaemon(t, OverlapSkip, CatchUpNone, time.Time{})
+		dt.wake(dt.waiting(), at("10:01"))
+		dt.expectRun(at("10:01"))
+		dt.wake(dt.waiting(), at("10:02"))
+		dt.waiting()
+		dt.release <- struct{}{}
+		dt.wake(dt.waiting(), at("10:03"))
+		dt.expectRun(at("10:03"))
+		if log := dt.stop(); !strings.Contains(log, "run skipped") {
+			t.Errorf("Skipped run not logged:\n%s", log)
+		}
+	}
+
+	t.Log("Given a run due while the previous one is active, and overlap queue:")
+	{
+		dt := startDaemon(t, OverlapQueue, CatchUpNone, time.Time{})
+		dt.wake(dt.waiting(), at("10:01"))
+		dt.expectRun(at("10:01"))
+		dt.wake(dt.waiting(), at("10:02"))
+		dt.waiting()
+		dt.release <- struct{}{}
+		dt.expectRun(at("10:02"))
+		if log := dt.stop(); !strings.Contains(log, "run queued") {
+			t.Errorf("Queued run not logged:\n%s", log)
+		}
+	}
+}
+
+func TestDaemonCatchUp(t *testing.T) {
+	t.Log("Given three runs missed and catch up all:")
+	{
+		dt := startDaemon(t, OverlapSkip, CatchUpAll, at("09:57"))
+		for _, missed := range []string{"09:58", "09:59", "10:00"} {
+			dt.waiting()
+			dt.expectRun(at(missed))
+			dt.release <- struct{}{}
+		}
+		if log := dt.stop(); !strings.Contains(log, "catching up 3 missed run(s)") {
+			t.Errorf("Catch up not logged:\n%s", log)
+		}
+	}
+
+	t.Log("Given three runs missed and catch up once:")
+	{
+		dt := startDaemon(t, OverlapSkip, CatchUpOnce, at("09:57"))
+		dt.waiting()
+		dt.expectRun(daemonStart)
+		dt.release <- struct{}{}
+		dt.stop()
+	}
+
+	t.Log("Given an entry which never ran:")
+	{
+		dt := startDaemon(t, OverlapSkip, CatchUpAll, time.Time{})
+		dt.wake(dt.waiting(), at("10:01"))
+		dt.expectRun(at("10:01"))
+		dt.stop()
+	}
+}
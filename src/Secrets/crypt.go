@@ -0,0 +1,120 @@
+package Secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// pbkdf2Iterations is the work factor of new secrets files.
+const pbkdf2Iterations = 600000
+
+// encryptedFile is the on-disk form of a secrets file. Data is a
+// JSON object of name to value, sealed with AES-256-GCM under a key
+// derived from the passphrase with PBKDF2-SHA256.
+type encryptedFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+func fileKey(passphrase string, salt []byte, iter int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iter, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// LoadEncrypted decrypts a secrets file. A file which does not
+// exist holds no secrets.
+func LoadEncrypted(path, passphrase string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ef encryptedFile
+	if err := json.Unmarshal(data, &ef); err != nil {
+		return nil, fmt.Errorf("%s: not a secrets file: %v", path, err)
+	}
+	if ef.Version != 1 || ef.KDF != "pbkdf2-sha256" {
+		return nil, fmt.Errorf("%s: unsupported secrets file version %d, kdf %q", path, ef.Version, ef.KDF)
+	}
+	aead, err := fileKey(passphrase, ef.Salt, ef.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(ef.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("%s: bad nonce", path)
+	}
+	plain, err := aead.Open(nil, ef.Nonce, ef.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: wrong passphrase or damaged file", path)
+	}
+	vals := map[string]string{}
+	if err := json.Unmarshal(plain, &vals); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return vals, nil
+}
+
+// SaveEncrypted writes vals to a secrets file readable only by its
+// owner, with a fresh salt and nonce.
+func SaveEncrypted(path, passphrase string, vals map[string]string) error {
+	if passphrase == "" {
+		return errors.New("empty passphrase")
+	}
+	plain, err := json.Marshal(vals)
+	if err != nil {
+		return err
+	}
+	ef := encryptedFile{Version: 1, KDF: "pbkdf2-sha256", Iterations: pbkdf2Iterations,
+		Salt: make([]byte, 16)}
+	rand.Read(ef.Salt)
+	aead, err := fileKey(passphrase, ef.Salt, ef.Iterations)
+	if err != nil {
+		return err
+	}
+	ef.Nonce = make([]byte, aead.NonceSize())
+	rand.Read(ef.Nonce)
+	ef.Data = aead.Seal(nil, ef.Nonce, plain, nil)
+	data, err := json.MarshalIndent(ef, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0600)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,176 @@
+package Secrets
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces secret values in output.
+const Mask = "********"
+
+// maxPartialLine is how much of an unterminated line MaskWriter
+// holds back before writing all but its possibly secret tail.
+const maxPartialLine = 4096
+
+// Masker replaces every known secret value with Mask. The zero
+// value masks nothing; values are added as secrets are resolved.
+type Masker struct {
+	mu       sync.RWMutex
+	values   []string
+	replacer *strings.Replacer
+}
+
+// Add registers a value to be masked. Empty values are ignored.
+func (m *Masker) Add(v string) {
+	if v == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, have := range m.values {
+		if have == v {
+			return
+		}
+	}
+	m.values = append(m.values, v)
+	// Longer values first, so that a secret containing another is
+	//   masked whole.
+	sort.Slice(m.values, func(i, j int) bool { return len(m.values[i]) > len(m.values[j]) })
+	var pairs []string
+	for _, have := range m.values {
+		pairs = append(pairs, have, Mask)
+	}
+	m.replacer = strings.NewReplacer(pairs...)
+}
+
+// Mask returns s with all known values masked.
+func (m *Masker) Mask(s string) string {
+	if m == nil {
+		return s
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.replacer == nil {
+		return s
+	}
+	return m.replacer.Replace(s)
+}
+
+// MaskStrings masks, in place, every string reachable from v, a
+// pointer: those of exported struct fields, of slice, array and
+// map elements and of pointers. Values are masked this way before
+// they are quoted or encoded, as escaping could hide a secret from
+// Mask.
+func (m *Masker) MaskStrings(v interface{}) {
+	m.maskValue(reflect.ValueOf(v))
+}
+
+func (m *Masker) maskValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			m.maskValue(v.Elem())
+		}
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(m.Mask(v.String()))
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			m.maskValue(v.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				m.maskValue(v.Field(i))
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(iter.Value())
+			m.maskValue(e)
+			v.SetMapIndex(iter.Key(), e)
+		}
+	}
+}
+
+// cut returns where s may be split without splitting a value to be
+// masked: before the last len(longest value)-1 bytes, which may
+// begin a value completed by later output, and before any value
+// which would otherwise straddle the split.
+func (m *Masker) cut(s string) int {
+	if m == nil {
+		return len(s)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.values) == 0 {
+		return len(s)
+	}
+	n := max(0, len(s)-(len(m.values[0])-1))
+	for moved := true; moved; {
+		moved = false
+		for _, v := range m.values {
+			from := max(0, n-len(v)+1)
+			if i := strings.Index(s[from:], v); i >= 0 && from+i < n {
+				n, moved = from+i, true
+			}
+		}
+	}
+	return n
+}
+
+// Writer returns a writer masking what it passes on to w.
+func (m *Masker) Writer(w io.Writer) *MaskWriter {
+	return &MaskWriter{m: m, w: w}
+}
+
+// MaskWriter masks secrets in a stream. Output is passed on a line
+// at a time, so that a value split across writes is still masked;
+// a partial line is held back until its newline arrives or Flush is
+// called. Once it grows beyond 4 KiB, all of it but a tail which may
+// be the start of a secret is written.
+type MaskWriter struct {
+	m   *Masker
+	w   io.Writer
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (mw *MaskWriter) Write(p []byte) (int, error) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	mw.buf = append(mw.buf, p...)
+	n := bytes.LastIndexByte(mw.buf, '\n') + 1
+	if n == 0 && len(mw.buf) > maxPartialLine {
+		n = mw.m.cut(string(mw.buf))
+	}
+	if n > 0 {
+		if err := mw.emit(n); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes any held back partial line.
+func (mw *MaskWriter) Flush() error {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	return mw.emit(len(mw.buf))
+}
+
+func (mw *MaskWriter) emit(n int) error {
+	if n == 0 {
+		return nil
+	}
+	_, err := io.WriteString(mw.w, mw.m.Mask(string(mw.buf[:n])))
+	mw.buf = append(mw.buf[:0], mw.buf[n:]...)
+	return err
+}
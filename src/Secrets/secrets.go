@@ -0,0 +1,133 @@
+// Package Secrets resolves the secret values declared in a command
+// file header and masks them in output.
+package Secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	ds "go_cmdrX/src/DataStrucs"
+)
+
+// PassphraseEnv names the environment variable holding the
+// passphrase of encrypted secrets files.
+const PassphraseEnv = "CMDRX_SECRETS_PASSPHRASE"
+
+// Store resolves declared secrets on first use, so that a job
+// batch only needs the sources of the secrets it references. Every
+// resolved value is added to the store's Masker.
+type Store struct {
+	decls   map[string]ds.CmdSecret
+	baseDir string
+	mask    *Masker
+	Getenv  func(string) (string, bool)
+
+	mu     sync.Mutex
+	values map[string]string
+	files  map[string]map[string]string
+}
+
+// NewStore checks the declarations. Relative file paths are taken
+// from baseDir, normally the command file's directory.
+func NewStore(decls []ds.CmdSecret, baseDir string, mask *Masker) (*Store, error) {
+	s := &Store{decls: map[string]ds.CmdSecret{}, baseDir: baseDir, mask: mask, Getenv: os.LookupEnv,
+		values: map[string]string{}, files: map[string]map[string]string{}}
+	if s.mask == nil {
+		s.mask = &Masker{}
+	}
+	var errs []error
+	for _, d := range decls {
+		sources := 0
+		for _, src := range []string{d.FromEnv, d.FromFile, d.FromEncryptedFile} {
+			if src != "" {
+				sources++
+			}
+		}
+		switch {
+		case d.Name == "":
+			errs = append(errs, errors.New("secret without a name"))
+		case s.decls[d.Name].Name != "":
+			errs = append(errs, fmt.Errorf("secret %q: declared twice", d.Name))
+		case sources != 1:
+			errs = append(errs, fmt.Errorf("secret %q: needs exactly one of from_env, from_file and from_encrypted_file", d.Name))
+		default:
+			s.decls[d.Name] = d
+		}
+	}
+	return s, errors.Join(errs...)
+}
+
+// Masker returns the masker holding the resolved values.
+func (s *Store) Masker() *Masker {
+	return s.mask
+}
+
+// Get returns the value of a declared secret.
+func (s *Store) Get(name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.values[name]; ok {
+		return v, nil
+	}
+	d, ok := s.decls[name]
+	if !ok {
+		return "", fmt.Errorf("secret %q is not declared in jobs_header.secrets", name)
+	}
+	v, err := s.resolve(d)
+	if err != nil {
+		return "", fmt.Errorf("secret %q: %v", name, err)
+	}
+	s.values[name] = v
+	s.mask.Add(v)
+	return v, nil
+}
+
+func (s *Store) path(p string) string {
+	if filepath.IsAbs(p) || s.baseDir == "" {
+		return p
+	}
+	return filepath.Join(s.baseDir, p)
+}
+
+func (s *Store) resolve(d ds.CmdSecret) (string, error) {
+	switch {
+	case d.FromEnv != "":
+		v, ok := s.Getenv(d.FromEnv)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", d.FromEnv)
+		}
+		return v, nil
+	case d.FromFile != "":
+		data, err := os.ReadFile(s.path(d.FromFile))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	path := s.path(d.FromEncryptedFile)
+	vals, ok := s.files[path]
+	if !ok {
+		pass, set := s.Getenv(PassphraseEnv)
+		if !set {
+			return "", fmt.Errorf("%s is not set", PassphraseEnv)
+		}
+		var err error
+		if vals, err = LoadEncrypted(path, pass); err != nil {
+			return "", err
+		}
+		s.files[path] = vals
+	}
+	key := d.Key
+	if key == "" {
+		key = d.Name
+	}
+	v, ok := vals[key]
+	if !ok {
+		return "", fmt.Errorf("no entry %q in %s", key, path)
+	}
+	return v, nil
+}
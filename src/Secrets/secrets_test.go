@@ -0,0 +1,130 @@
+package Secrets
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ds "go_cmdrX/src/DataStrucs"
+)
+
+func TestMaskWriter(t *testing.T) {
+	m := &Masker{}
+	m.Add("tok")
+	m.Add("s3cr3t-tok")
+	m.Add("")
+	var out bytes.Buffer
+	w := m.Writer(&out)
+
+	t.Log("Given a secret split across writes:")
+	{
+		w.Write([]byte("push https://s3cr"))
+		if out.Len() != 0 {
+			t.Errorf("Partial line written early: %q", out.String())
+		}
+		w.Write([]byte("3t-tok@host\nnext tok"))
+		w.Flush()
+		want := "push https://" + Mask + "@host\nnext " + Mask
+		if out.String() != want {
+			t.Errorf("Got %q, want %q", out.String(), want)
+		}
+	}
+
+	t.Log("Given a long line without a newline:")
+	{
+		out.Reset()
+		long := bytes.Repeat([]byte("x"), maxPartialLine)
+		w.Write(append(long, "s3cr"...))
+		if want := maxPartialLine + 4 - len("s3cr3t-tok") + 1; out.Len() != want {
+			t.Errorf("Long partial line: %d bytes written, want %d", out.Len(), want)
+		}
+		w.Write([]byte("3t-tok"))
+		w.Flush()
+		if want := string(long) + Mask; out.String() != want {
+			t.Errorf("Secret at a forced split leaked: %q", out.String()[maxPartialLine-8:])
+		}
+	}
+
+	t.Log("Given a long line with a secret at the forced split:")
+	{
+		out.Reset()
+		long := bytes.Repeat([]byte("x"), maxPartialLine)
+		w.Write(append(long, "s3cr3t-tok"...))
+		w.Flush()
+		if want := string(long) + Mask; out.String() != want {
+			t.Errorf("Secret at a forced split leaked: %q", out.String()[maxPartialLine-8:])
+		}
+	}
+}
+
+func TestMaskStrings(t *testing.T) {
+	m := &Masker{}
+	m.Add(`p"w`)
+	v := struct {
+		Argv []string
+		Env  map[string]string
+		Next *struct{ Dir string }
+		n    string
+	}{[]string{`--pw=p"w`}, map[string]string{"PW": `p"w`}, &struct{ Dir string }{`/p"w`}, `p"w`}
+
+	t.Log("Given secrets in fields, slices, maps and pointers:")
+	{
+		m.MaskStrings(&v)
+		if v.Argv[0] != "--pw="+Mask || v.Env["PW"] != Mask || v.Next.Dir != "/"+Mask || v.n != `p"w` {
+			t.Errorf("Got %+v %+v", v, *v.Next)
+		}
+	}
+}
+
+func TestStoreSources(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "token.txt"), []byte("from-file\r\n"), 0600)
+	os.Setenv(PassphraseEnv, "pass phrase")
+	defer os.Unsetenv(PassphraseEnv)
+	if err := SaveEncrypted(filepath.Join(dir, "vault.json"), "pass phrase", map[string]string{"gh": "from-vault"}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "vault.json")); bytes.Contains(data, []byte("from-vault")) {
+		t.Error("Secrets file holds the plain value")
+	}
+
+	mask := &Masker{}
+	s, err := NewStore([]ds.CmdSecret{
+		{Name: "A", FromEnv: "TEST_SECRET_A"},
+		{Name: "B", FromFile: "token.txt"},
+		{Name: "C", FromEncryptedFile: "vault.json", Key: "gh"},
+		{Name: "D", FromEncryptedFile: "vault.json"},
+	}, dir, mask)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Getenv = func(k string) (string, bool) {
+		if k == "TEST_SECRET_A" {
+			return "from-env", true
+		}
+		return os.LookupEnv(k)
+	}
+	for name, want := range map[string]string{"A": "from-env", "B": "from-file", "C": "from-vault"} {
+		if got, err := s.Get(name); err != nil || got != want {
+			t.Errorf("%s: got %q, %v", name, got, err)
+		}
+	}
+	if _, err := s.Get("D"); err == nil || !strings.Contains(err.Error(), `no entry "D"`) {
+		t.Errorf("D: %v", err)
+	}
+	if _, err := s.Get("E"); err == nil {
+		t.Error("Undeclared secret resolved")
+	}
+	if got := mask.Mask("from-env from-file from-vault"); strings.Contains(got, "from") {
+		t.Errorf("Resolved values not masked: %q", got)
+	}
+
+	if _, err := LoadEncrypted(filepath.Join(dir, "vault.json"), "wrong"); err == nil {
+		t.Error("Wrong passphrase accepted")
+	}
+	if _, err := NewStore([]ds.CmdSecret{{Name: "X"}, {Name: "Y", FromEnv: "a", FromFile: "b"}}, dir, nil); err == nil {
+		t.Error("Secrets without exactly one source accepted")
+	}
+}
@@ -0,0 +1,378 @@
+// Package TomlParser reads TOML command files. The header is the
+// [header] table and each job a [[jobs]] table; the keys within them
+// are those of the JSON command file, and cmd_elements is an array
+// of strings.
+//
+//	[header]
+//	log_path_file_name = "./cmdrx/install.log"
+//
+//	[[jobs]]
+//	cmd_display_name = "Copy1"
+//	cmd_type = "Console"
+//	cmd_timeout_in_minutes = 15.0
+//	cmd_elements = ["cmd.exe", "/c", "copy", 'D:\T06\*.*', 'D:\T08\']
+//
+// Profiles use the same names: [profiles.prod.header] and
+// [[profiles.prod.jobs]]. An optional top-level schema_version
+// comes before the first table. Decoding is strict: unknown keys
+// and values of the wrong type are errors, reported with their line
+// and column. Keys starting with x- are ignored, and DecodeOptions
+// may make other unknown keys ignored too.
+package TomlParser
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ds "go_cmdrX/src/DataStrucs"
+	eu "go_cmdrX/src/ErrUtil"
+	"go_cmdrX/src/stringmgr/suggest"
+
+	"github.com/BurntSushi/toml"
+)
+
+// tomlNames renames the JSON keys which are spelled differently in
+// TOML, wherever they appear.
+var tomlNames = map[string]string{
+	"jobs_header":  "header",
+	"command_jobs": "jobs",
+}
+
+var (
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+	cmdElementType = reflect.TypeOf(ds.CmdElement{})
+
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// DecodeTOMLCmds decodes a TOML command file. All problems found
+// are returned together.
+func DecodeTOMLCmds(r io.Reader, opts ds.DecodeOptions) (ds.JsonCmdBatch, error) {
+	var batch ds.JsonCmdBatch
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return batch, err
+	}
+	var doc map[string]interface{}
+	if _, err := toml.Decode(string(data), &doc); err != nil {
+		var pe toml.ParseError
+		if errors.As(err, &pe) {
+			return batch, &eu.PositionError{Line: pe.Position.Line, Col: pe.Position.Col, Msg: pe.Message}
+		}
+		return batch, err
+	}
+	d := decoder{pos: keyPositions(string(data)), strict: !opts.Lenient}
+	if v, ok := doc["schema_version"]; ok {
+		d.decode(v, reflect.ValueOf(&batch.SchemaVersion).Elem(), "schema_version")
+		delete(doc, "schema_version")
+	}
+	d.decode(doc, reflect.ValueOf(&batch.Batch).Elem(), "")
+	return batch, errors.Join(d.errs...)
+}
+
+type position struct {
+	line, col int
+}
+
+type decoder struct {
+	pos    map[string]position
+	strict bool
+	errs   []error
+}
+
+func (d *decoder) fail(path, format string, a ...interface{}) {
+	msg := fmt.Sprintf("%s: %s", path, fmt.Sprintf(format, a...))
+	if p, ok := d.position(path); ok {
+		d.errs = append(d.errs, &eu.PositionError{Line: p.line, Col: p.col, Msg: msg})
+		return
+	}
+	d.errs = append(d.errs, errors.New(msg))
+}
+
+// position returns the position of path. Array elements have none
+// of their own, so an element takes that of the key holding the
+// array ("jobs[0].cmd_elements[1]" that of "jobs[0].cmd_elements").
+func (d *decoder) position(path string) (position, bool) {
+	for {
+		if p, ok := d.pos[path]; ok {
+			return p, true
+		}
+		i := strings.LastIndex(path, "[")
+		if i < 0 || !strings.HasSuffix(path, "]") {
+			return position{}, false
+		}
+		path = path[:i]
+	}
+}
+
+// decode stores x, a value decoded by the toml package, in v.
+func (d *decoder) decode(x interface{}, v reflect.Value, path string) {
+	switch {
+	case v.Type() == rawMessageType:
+		data, err := json.Marshal(x)
+		if err != nil {
+			d.fail(path, "%v", err)
+			return
+		}
+		v.SetBytes(data)
+		return
+	case v.Type() == cmdElementType:
+		s, ok := x.(string)
+		if !ok {
+			d.fail(path, "expected a string, found %s", typeName(x))
+			return
+		}
+		v.Set(reflect.ValueOf(ds.CmdElement{CmdUnit: s}))
+		return
+	case v.Addr().Type().Implements(textUnmarshalerType):
+		// Typed settings such as time outs. Text which does not
+		// parse is kept for validation, since a macro may make it
+		// valid.
+		var text string
+		switch s := x.(type) {
+		case string:
+			text = s
+		case int64:
+			text = strconv.FormatInt(s, 10)
+		case float64:
+			text = strconv.FormatFloat(s, 'f', -1, 64)
+		case time.Time:
+			text = s.Format(time.RFC3339)
+		default:
+			d.fail(path, "expected a string or a number, found %s", typeName(x))
+			return
+		}
+		v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		s, ok := x.(string)
+		if !ok {
+			d.fail(path, "expected a string, found %s", typeName(x))
+			return
+		}
+		v.SetString(s)
+	case reflect.Int:
+		i, ok := x.(int64)
+		if !ok {
+			d.fail(path, "expected an integer, found %s", typeName(x))
+			return
+		}
+		v.SetInt(i)
+	case reflect.Bool:
+		b, ok := x.(bool)
+		if !ok {
+			d.fail(path, "expected true or false, found %s", typeName(x))
+			return
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		items := reflect.ValueOf(x)
+		if items.Kind() != reflect.Slice {
+			d.fail(path, "expected an array, found %s", typeName(x))
+			return
+		}
+		s := reflect.MakeSlice(v.Type(), items.Len(), items.Len())
+		for i := 0; i < items.Len(); i++ {
+			d.decode(items.Index(i).Interface(), s.Index(i), fmt.Sprintf("%s[%d]", path, i))
+		}
+		v.Set(s)
+	case reflect.Map:
+		m, ok := x.(map[string]interface{})
+		if !ok {
+			d.fail(path, "expected a table, found %s", typeName(x))
+			return
+		}
+		out := reflect.MakeMap(v.Type())
+		for _, k := range sortedKeys(m) {
+			e := reflect.New(v.Type().Elem()).Elem()
+			d.decode(m[k], e, join(path, k))
+			out.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), e)
+		}
+		v.Set(out)
+	case reflect.Struct:
+		m, ok := x.(map[string]interface{})
+		if !ok {
+			d.fail(path, "expected a table, found %s", typeName(x))
+			return
+		}
+		fields := tomlFields(v.Type())
+		for _, k := range sortedKeys(m) {
+			i, ok := fields[k]
+			if !ok {
+				if d.strict && !ds.IsExtensionKey(k) {
+					d.fail(join(path, k), "unknown key%s", suggest.DidYouMean(k, fieldNames(fields)))
+				}
+				continue
+			}
+			if ot := ds.ProfileOverlay(v.Type(), i); ot != nil {
+				d.decode(m[k], reflect.New(ot).Elem(), join(path, k))
+			}
+			d.decode(m[k], v.Field(i), join(path, k))
+		}
+	default:
+		d.fail(path, "unsupported field type %s", v.Type())
+	}
+}
+
+// tomlFields maps the TOML keys of a struct's fields to their
+// indexes.
+func tomlFields(t reflect.Type) map[string]int {
+	fields := map[string]int{}
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		if n, ok := tomlNames[name]; ok {
+			name = n
+		}
+		fields[name] = i
+	}
+	return fields
+}
+
+// fieldNames returns the keys of fields, sorted.
+func fieldNames(fields map[string]int) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func typeName(x interface{}) string {
+	switch x.(type) {
+	case string:
+		return "a string"
+	case int64:
+		return "an integer"
+	case float64:
+		return "a float"
+	case bool:
+		return "a boolean"
+	case map[string]interface{}:
+		return "a table"
+	}
+	if reflect.ValueOf(x).Kind() == reflect.Slice {
+		return "an array"
+	}
+	return fmt.Sprintf("a %T", x)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// keyPositions maps the path of each key and table header in src,
+// spelled as the decoder spells it ("jobs[1].cmd_type"), to its
+// position. It is a line scanner rather than a parser: it assumes
+// the file already parsed, and dotted keys are taken literally.
+func keyPositions(src string) map[string]position {
+	pos := map[string]position{}
+	counts := map[string]int{}
+	prefix := ""
+	depth := 0
+	inString := ""
+	for i, line := range strings.Split(src, "\n") {
+		if inString != "" {
+			if strings.Count(line, inString)%2 == 1 {
+				inString = ""
+			}
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		col := len(line) - len(strings.TrimLeft(line, " \t")) + 1
+		if depth > 0 {
+			depth += bracketDepth(trimmed)
+			continue
+		}
+		switch {
+		case trimmed == "" || trimmed[0] == '#':
+		case strings.HasPrefix(trimmed, "[["):
+			name := tableName(trimmed[2:], "]]")
+			prefix = fmt.Sprintf("%s[%d]", name, counts[name])
+			counts[name]++
+			pos[prefix] = position{i + 1, col}
+		case trimmed[0] == '[':
+			prefix = tableName(trimmed[1:], "]")
+			pos[prefix] = position{i + 1, col}
+		default:
+			eq := strings.Index(trimmed, "=")
+			if eq < 0 {
+				continue
+			}
+			key := strings.Trim(strings.TrimSpace(trimmed[:eq]), `"'`)
+			pos[join(prefix, key)] = position{i + 1, col}
+			value := strings.TrimSpace(trimmed[eq+1:])
+			for _, q := range []string{`"""`, `'''`} {
+				if strings.HasPrefix(value, q) && strings.Count(value, q) == 1 {
+					inString = q
+				}
+			}
+			depth = bracketDepth(value)
+		}
+	}
+	return pos
+}
+
+func tableName(s, end string) string {
+	if i := strings.Index(s, end); i >= 0 {
+		s = s[:i]
+	}
+	var parts []string
+	for _, p := range strings.Split(s, ".") {
+		parts = append(parts, strings.Trim(strings.TrimSpace(p), `"'`))
+	}
+	return strings.Join(parts, ".")
+}
+
+// bracketDepth returns the net number of array brackets opened by
+// s, ignoring brackets in strings and comments.
+func bracketDepth(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return depth
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		}
+	}
+	return depth
+}
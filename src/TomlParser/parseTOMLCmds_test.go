@@ -0,0 +1,126 @@
+package TomlParser
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	ds "go_cmdrX/src/DataStrucs"
+	pf "go_cmdrX/src/Profiles"
+)
+
+const testFile = `# Copies with a shared header
+[header]
+log_file_retention_in_days = 7
+log_path_file_name = "./cmdrx/install.log"
+watch_paths = [
+  "src",   # sources
+  "*.go",
+]
+
+[[parameters]]
+name = "target"
+default = 'D:\T08\'
+
+[[jobs]]
+cmd_display_name = "Copy1"
+cmd_type = "Console"
+cmd_timeout_in_minutes = 15.0
+cmd_elements = ["cmd.exe", "/c", "copy", 'D:\T06\*.*', "%(target)%"]
+
+[[jobs]]
+cmd_display_name = "Copy2"
+cmd_timeout_in_minutes = 30
+dump_proc_tree_on_idle_timeout = true
+cmd_elements = ["cmd.exe", "/c", "copy", 'D:\T07\*.*', "%(target)%"]
+
+[profiles.prod]
+remove_jobs = ["Copy1"]
+
+[profiles.prod.header]
+log_path_file_name = "./cmdrx/prod.log"
+`
+
+func TestDecodeTOMLCmds(t *testing.T) {
+	t.Log("Given a header, parameters, jobs and a profile:")
+	{
+		b, err := DecodeTOMLCmds(strings.NewReader(testFile), ds.DecodeOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h := b.Batch.Hdr; h.LogFileRetentionInDays != 7 || len(h.WatchPaths) != 2 {
+			t.Errorf("Header %+v", h)
+		}
+		if p := b.Batch.Params; len(p) != 1 || p[0].Default != `D:\T08\` {
+			t.Errorf("Parameters %+v", p)
+		}
+		jobs := b.Batch.Jobs
+		if len(jobs) != 2 || jobs[0].TimeOutMinutes.Duration != 15*time.Minute || jobs[1].TimeOutMinutes.Duration != 30*time.Minute || !jobs[1].DumpProcTreeOnIdle {
+			t.Fatalf("Jobs %+v", jobs)
+		}
+		if e := jobs[0].CmdElements; len(e) != 5 || e[3].CmdUnit != `D:\T06\*.*` {
+			t.Errorf("Elements %v", e)
+		}
+		if err := pf.Apply(&b, "prod", ds.DecodeOptions{}); err != nil || len(b.Batch.Jobs) != 1 || b.Batch.Hdr.LogPathFileName != "./cmdrx/prod.log" {
+			t.Errorf("Profile not applied: %v %+v", err, b.Batch)
+		}
+	}
+
+	t.Log("Given unknown keys and values of the wrong type:")
+	{
+		bad := "[header]\nlog_file_retention_in_days = \"7\"\n\n[[jobs]]\ncmd_display_name = \"A\"\n\n[[jobs]]\n  cmd_timeout = 5\ncmd_elements = [1]\n\n[extra]\n\n[[jobs]]\ncmd_type = 5\n"
+		_, err := DecodeTOMLCmds(strings.NewReader(bad), ds.DecodeOptions{})
+		for _, want := range []string{
+			"line 2, column 1: header.log_file_retention_in_days: expected an integer, found a string",
+			"line 8, column 3: jobs[1].cmd_timeout: unknown key",
+			"line 9, column 1: jobs[1].cmd_elements[0]: expected a string, found an integer",
+			"line 11, column 1: extra: unknown key",
+			"line 14, column 1: jobs[2].cmd_type: expected a string, found an integer",
+		} {
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("Error %v\nwant %s", err, want)
+			}
+		}
+	}
+
+	t.Log("Given an error inside an array spanning lines:")
+	{
+		doc := "[[jobs]]\ncmd_display_name = \"A\"\n  cmd_elements = [\n    \"cmd.exe\",\n    2,\n  ]\n"
+		_, err := DecodeTOMLCmds(strings.NewReader(doc), ds.DecodeOptions{})
+		want := "line 3, column 3: jobs[0].cmd_elements[1]: expected a string, found an integer"
+		if err == nil || err.Error() != want {
+			t.Errorf("Error %v\nwant %s", err, want)
+		}
+	}
+
+	t.Log("Given a misspelt key and annotations:")
+	{
+		doc := "x-owner = \"ops\"\n\n[[jobs]]\ncmd_display_name = \"A\"\ncmd_timout_in_minutes = 5\nx-note = \"nightly\"\n"
+		_, err := DecodeTOMLCmds(strings.NewReader(doc), ds.DecodeOptions{})
+		want := `line 5, column 1: jobs[0].cmd_timout_in_minutes: unknown key; did you mean "cmd_timeout_in_minutes"?`
+		if err == nil || err.Error() != want {
+			t.Errorf("Error %v\nwant %s", err, want)
+		}
+		if _, err := DecodeTOMLCmds(strings.NewReader(doc), ds.DecodeOptions{Lenient: true}); err != nil {
+			t.Errorf("Lenient decoding: %v", err)
+		}
+	}
+
+	t.Log("Given a misspelt key in a profile overlay:")
+	{
+		doc := "[profiles.prod.header]\nlog_path_fle_name = \"p.log\"\nx-why = 1\n"
+		_, err := DecodeTOMLCmds(strings.NewReader(doc), ds.DecodeOptions{})
+		want := `line 2, column 1: profiles.prod.header.log_path_fle_name: unknown key; did you mean "log_path_file_name"?`
+		if err == nil || err.Error() != want {
+			t.Errorf("Error %v\nwant %s", err, want)
+		}
+	}
+
+	t.Log("Given a syntax error:")
+	{
+		_, err := DecodeTOMLCmds(strings.NewReader("[header]\nlog_path_file_name = \"unterminated\n"), ds.DecodeOptions{})
+		if err == nil || !strings.HasPrefix(err.Error(), "line 2, column ") {
+			t.Errorf("Error %v", err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Package XmlParser reads the XML command files of the older CmdrX
+// tool (see cmdrX_xml) into the types of the JSON command file.
+package XmlParser
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	ds "go_cmdrX/src/DataStrucs"
+	eu "go_cmdrX/src/ErrUtil"
+)
+
+// xmlCmds mirrors the <Commands> document. Element names, including
+// the misspelt ExectuteCommand, are those of the original schema.
+type xmlCmds struct {
+	XMLName xml.Name      `xml:"Commands"`
+	Hdr     xmlCmdHdr     `xml:"CommandFileHeader"`
+	Jobs    []xmlCmdEntry `xml:"ExectuteCommand"`
+}
+
+type xmlCmdHdr struct {
+	LogFileRetentionInDays string `xml:"DefaultLogFileRetentionInDays"`
+	CmdExeDirectory        string `xml:"DefaultCommandExeDirectory"`
+	LogPathFileName        string `xml:"DefaultCommandOutputLogFilePathName"`
+}
+
+type xmlCmdEntry struct {
+	DisplayName               string `xml:"CommandDisplayName"`
+	Type                      string `xml:"ConsoleCommandType"`
+	KillOnExitCodeGreaterThan string `xml:"KillJobsRunOnExitCodeGreaterThan"`
+	KillOnExitCodeLessThan    string `xml:"KillJobsRunOnExitCodeLessThan"`
+	LogPathBaseName           string `xml:"CommandOutputLogFilePathBaseName"`
+	TimeOutMinutes            string `xml:"CommandTimeOutInMinutes"`
+	Executor                  string `xml:"DefaultConsoleCommandExecutor"`
+	ExecutorArgs              string `xml:"ConsoleCommandExeArguments"`
+	ExeDir                    string `xml:"ExecuteInDir"`
+	Target                    string `xml:"ExecutableTarget"`
+	Command                   string `xml:"CommandToExecute"`
+	Modifier                  string `xml:"CommandModifier"`
+	Arguments                 string `xml:"CommandArguments"`
+}
+
+// utf8BOM is written at the start of files saved by Windows editors.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// ParseXMLCmds reads an XML command file.
+func ParseXMLCmds(fileNamePath string) (ds.JsonCmdBatch, error) {
+	data, err := os.ReadFile(fileNamePath)
+	if err != nil {
+		return ds.JsonCmdBatch{}, eu.ReadError(err)
+	}
+	batch, err := DecodeXMLCmds(bytes.NewReader(data))
+	if err != nil {
+		return batch, eu.FileError(eu.Parse, fileNamePath, fmt.Errorf("XML Parsing Error: %w", err))
+	}
+	return batch, nil
+}
+
+// DecodeXMLCmds decodes an XML command file. Each job's argv is
+// built from the non-empty elements DefaultConsoleCommandExecutor,
+// ConsoleCommandExeArguments, ExecutableTarget, CommandToExecute,
+// CommandModifier and CommandArguments, in that order. Each is one
+// argv element, but for ConsoleCommandExeArguments, CommandModifier
+// and CommandArguments, which are split into arguments as splitArgs
+// does.
+func DecodeXMLCmds(r io.Reader) (ds.JsonCmdBatch, error) {
+	var batch ds.JsonCmdBatch
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return batch, err
+	}
+	var doc xmlCmds
+	if err := xml.Unmarshal(bytes.TrimPrefix(data, utf8BOM), &doc); err != nil {
+		return batch, err
+	}
+
+	hdr := &batch.Batch.Hdr
+	if s := strings.TrimSpace(doc.Hdr.LogFileRetentionInDays); s != "" {
+		if hdr.LogFileRetentionInDays, err = strconv.Atoi(s); err != nil {
+			return batch, fmt.Errorf("DefaultLogFileRetentionInDays: %v", err)
+		}
+	}
+	hdr.CmdExeDirectory = strings.TrimSpace(doc.Hdr.CmdExeDirectory)
+	hdr.LogPathFileName = strings.TrimSpace(doc.Hdr.LogPathFileName)
+
+	for _, e := range doc.Jobs {
+		job := ds.CmdJob{
+			DisplayName:     strings.TrimSpace(e.DisplayName),
+			Type:            strings.TrimSpace(e.Type),
+			ExeDir:          strings.TrimSpace(e.ExeDir),
+			LogPathFileName: strings.TrimSpace(e.LogPathBaseName),
+		}
+		// Bad values are kept and reported by validation.
+		job.KillOnExitCodeGreaterThan.UnmarshalText([]byte(strings.TrimSpace(e.KillOnExitCodeGreaterThan)))
+		job.KillOnExitCodeLessThan.UnmarshalText([]byte(strings.TrimSpace(e.KillOnExitCodeLessThan)))
+		job.TimeOutMinutes.UnmarshalText([]byte(strings.TrimSpace(e.TimeOutMinutes)))
+		add := func(args ...string) {
+			for _, a := range args {
+				job.CmdElements = append(job.CmdElements, ds.CmdElement{CmdUnit: a})
+			}
+		}
+		one := func(el string) []string {
+			if el = strings.TrimSpace(el); el != "" {
+				return []string{el}
+			}
+			return nil
+		}
+		add(one(e.Executor)...)
+		add(splitArgs(e.ExecutorArgs)...)
+		add(one(e.Target)...)
+		add(one(e.Command)...)
+		add(splitArgs(e.Modifier)...)
+		add(splitArgs(e.Arguments)...)
+		batch.Batch.Jobs = append(batch.Batch.Jobs, job)
+	}
+	return batch, nil
+}
+
+// splitArgs splits s into arguments at white space, as a Windows
+// command line is: a double quoted part may hold white space, the
+// quotes are dropped, and backslashes are kept as they are.
+func splitArgs(s string) []string {
+	var args []string
+	var arg strings.Builder
+	inArg, quoted := false, false
+	for _, c := range s {
+		switch {
+		case c == '"':
+			inArg, quoted = true, !quoted
+		case !quoted && unicode.IsSpace(c):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			inArg = true
+			arg.WriteRune(c)
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
@@ -0,0 +1,113 @@
+package XmlParser
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	ds "go_cmdrX/src/DataStrucs"
+)
+
+func TestParseShippedFiles(t *testing.T) {
+	files, _ := filepath.Glob("../../cmdrX_xml/*.xml")
+	if len(files) == 0 {
+		t.Skip("no cmdrX_xml files")
+	}
+	t.Log("Given the shipped XML command files, with their BOM:")
+	{
+		for _, f := range files {
+			b, err := ParseXMLCmds(f)
+			if err != nil {
+				t.Errorf("%s: %v", f, err)
+				continue
+			}
+			if len(b.Batch.Jobs) == 0 || b.Batch.Hdr.LogPathFileName != "./cmdrx/install.log" {
+				t.Errorf("%s: parsed %+v", f, b)
+			}
+		}
+	}
+}
+
+func TestShippedArgv(t *testing.T) {
+	t.Log("Given the shipped CmdrXCmds003.xml:")
+	{
+		b, err := ParseXMLCmds("../../cmdrX_xml/CmdrXCmds003.xml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{
+			`cmd.exe|/c|del|.\CurrentConfig\*.*|/S|/Q`,
+			`cmd.exe|/c|robocopy|C:\Users\mike10\.atom\|D:\Atom\Config\CurrentConfig\PersonalConfig|*.json|*.cson|*.less|/DCOPY:DAT`,
+			`cmd.exe|/c|apm|--installed|--bare|>|packages.list`,
+			`cmd.exe|/c|mkdir|.\%(CURDATESTR)%_AtomConfig`,
+			`cmd.exe|/c|robocopy|.\CurrentConfig|.\%(CURDATESTR)%_AtomConfig|*.*|/E`,
+			`cmd.exe|/c|git|checkout|dev`,
+			`cmd.exe|/c|git|add|-A`,
+			`cmd.exe|/c|git|commit|-mLastest Atom Config`,
+			`cmd.exe|/c|git|push|origin|dev`,
+		}
+		var got []string
+		for _, j := range b.Batch.Jobs {
+			var argv []string
+			for _, e := range j.CmdElements {
+				argv = append(argv, e.CmdUnit)
+			}
+			got = append(got, strings.Join(argv, "|"))
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("argv\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+}
+
+func TestDecodeXMLCmds(t *testing.T) {
+	const doc = "\xEF\xBB\xBF" + `<?xml version="1.0" encoding="utf-8" ?>
+<Commands>
+	<CommandFileHeader>
+		<DefaultLogFileRetentionInDays>7</DefaultLogFileRetentionInDays>
+		<DefaultCommandExeDirectory> D:\Work </DefaultCommandExeDirectory>
+	</CommandFileHeader>
+	<ExectuteCommand>
+		<CommandDisplayName>Copy Config</CommandDisplayName>
+		<ConsoleCommandType>Console</ConsoleCommandType>
+		<KillJobsRunOnExitCodeGreaterThan>15</KillJobsRunOnExitCodeGreaterThan>
+		<CommandOutputLogFilePathBaseName>./logs/copy.log</CommandOutputLogFilePathBaseName>
+		<DefaultConsoleCommandExecutor>cmd.exe</DefaultConsoleCommandExecutor>
+		<ConsoleCommandExeArguments>/c</ConsoleCommandExeArguments>
+		<ExecutableTarget>robocopy</ExecutableTarget>
+		<CommandToExecute>C:\src\</CommandToExecute>
+		<CommandModifier></CommandModifier>
+		<CommandArguments>*.json  /DCOPY:DAT "/LOG:C:\Program Files\copy.log" /XF""x ""</CommandArguments>
+	</ExectuteCommand>
+</Commands>`
+
+	t.Log("Given a job using every argv element but one:")
+	{
+		b, err := DecodeXMLCmds(strings.NewReader(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h := b.Batch.Hdr; h.LogFileRetentionInDays != 7 || h.CmdExeDirectory != `D:\Work` {
+			t.Errorf("Header %+v", h)
+		}
+		j := b.Batch.Jobs[0]
+		var argv []string
+		for _, e := range j.CmdElements {
+			argv = append(argv, e.CmdUnit)
+		}
+		if got, want := strings.Join(argv, "|"), `cmd.exe|/c|robocopy|C:\src\|*.json|/DCOPY:DAT|/LOG:C:\Program Files\copy.log|/XFx|`; got != want {
+			t.Errorf("argv %q, want %q", got, want)
+		}
+		if j.LogPathFileName != "./logs/copy.log" || j.KillOnExitCodeGreaterThan != ds.NewOptInt(15) {
+			t.Errorf("Job %+v", j)
+		}
+	}
+
+	t.Log("Given a malformed file:")
+	{
+		if _, err := DecodeXMLCmds(strings.NewReader("<Commands><ExectuteCommand>")); err == nil {
+			t.Error("Truncated file accepted")
+		}
+	}
+}
@@ -0,0 +1,282 @@
+// Package YamlParser reads YAML command files. A YAML file has the
+// layout and key names of the JSON command file, with two
+// conveniences: cmd_elements may be a list of plain strings, and
+// anchors, aliases and merge keys (<<) may share job fragments.
+//
+//	x-console: &console
+//	  cmd_type: Console
+//	  cmd_timeout_in_minutes: "15.0"
+//	commands_batch:
+//	  command_jobs:
+//	    - <<: *console
+//	      cmd_display_name: Copy1
+//	      cmd_elements: [cmd.exe, /c, copy, 'D:\T06\*.*', 'D:\T08\']
+//
+// Keys starting with x-, such as x-console above, are ignored, so
+// fragments and annotations may be kept anywhere in the file.
+package YamlParser
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	ds "go_cmdrX/src/DataStrucs"
+	eu "go_cmdrX/src/ErrUtil"
+	"go_cmdrX/src/stringmgr/suggest"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+	cmdElementType = reflect.TypeOf(ds.CmdElement{})
+
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// DecodeYAMLCmds decodes a YAML command file. Errors give the line
+// and column of the offending value and its path in the batch;
+// all of them are returned together. Unknown keys are errors unless
+// opts are lenient.
+func DecodeYAMLCmds(r io.Reader, opts ds.DecodeOptions) (ds.JsonCmdBatch, error) {
+	var batch ds.JsonCmdBatch
+	var doc yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		if err == io.EOF {
+			return batch, errors.New("empty command file")
+		}
+		return batch, err
+	}
+	d := decoder{strict: !opts.Lenient}
+	d.decode(doc.Content[0], reflect.ValueOf(&batch).Elem(), "")
+	return batch, errors.Join(d.errs...)
+}
+
+type decoder struct {
+	strict bool
+	errs   []error
+}
+
+func (d *decoder) fail(n *yaml.Node, path, format string, a ...interface{}) {
+	if path == "" {
+		path = "document"
+	}
+	d.errs = append(d.errs, &eu.PositionError{Line: n.Line, Col: n.Column,
+		Msg: path + ": " + fmt.Sprintf(format, a...)})
+}
+
+// decode stores the value of n in v, which is addressable.
+func (d *decoder) decode(n *yaml.Node, v reflect.Value, path string) {
+	n = resolve(n)
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!null" {
+		return
+	}
+	switch {
+	case v.Type() == rawMessageType:
+		var x interface{}
+		if err := n.Decode(&x); err != nil {
+			d.fail(n, path, "%v", err)
+			return
+		}
+		data, err := json.Marshal(x)
+		if err != nil {
+			d.fail(n, path, "%v", err)
+			return
+		}
+		v.SetBytes(data)
+		return
+	case v.Type() == cmdElementType && n.Kind == yaml.ScalarNode:
+		v.Set(reflect.ValueOf(ds.CmdElement{CmdUnit: n.Value}))
+		return
+	case v.Addr().Type().Implements(textUnmarshalerType):
+		// Typed settings such as time outs. Text which does not
+		// parse is kept for validation, since a macro may make it
+		// valid.
+		if n.Kind != yaml.ScalarNode {
+			d.fail(n, path, "expected a scalar, found %s", kindName(n))
+			return
+		}
+		v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(n.Value))
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		if n.Kind != yaml.ScalarNode {
+			d.fail(n, path, "expected a string, found %s", kindName(n))
+			return
+		}
+		v.SetString(n.Value)
+	case reflect.Int:
+		i, err := strconv.Atoi(n.Value)
+		if n.Kind != yaml.ScalarNode || err != nil {
+			d.fail(n, path, "expected an integer, found %s", kindName(n))
+			return
+		}
+		v.SetInt(int64(i))
+	case reflect.Bool:
+		var b bool
+		if n.Kind != yaml.ScalarNode || n.Decode(&b) != nil {
+			d.fail(n, path, "expected true or false, found %s", kindName(n))
+			return
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if n.Kind != yaml.SequenceNode {
+			d.fail(n, path, "expected a list, found %s", kindName(n))
+			return
+		}
+		s := reflect.MakeSlice(v.Type(), len(n.Content), len(n.Content))
+		for i, c := range n.Content {
+			d.decode(c, s.Index(i), fmt.Sprintf("%s[%d]", path, i))
+		}
+		v.Set(s)
+	case reflect.Map:
+		pairs, ok := d.pairs(n, path)
+		if !ok {
+			return
+		}
+		m := reflect.MakeMap(v.Type())
+		for _, p := range pairs {
+			e := reflect.New(v.Type().Elem()).Elem()
+			d.decode(p.val, e, join(path, p.key))
+			m.SetMapIndex(reflect.ValueOf(p.key).Convert(v.Type().Key()), e)
+		}
+		v.Set(m)
+	case reflect.Struct:
+		pairs, ok := d.pairs(n, path)
+		if !ok {
+			return
+		}
+		fields := jsonFields(v.Type())
+		for _, p := range pairs {
+			i, ok := fields[p.key]
+			switch {
+			case ok:
+				if ot := ds.ProfileOverlay(v.Type(), i); ot != nil {
+					d.decode(p.val, reflect.New(ot).Elem(), join(path, p.key))
+				}
+				d.decode(p.val, v.Field(i), join(path, p.key))
+			case d.strict && !ds.IsExtensionKey(p.key):
+				d.fail(p.at, join(path, p.key), "unknown key%s", suggest.DidYouMean(p.key, fieldNames(fields)))
+			}
+		}
+	default:
+		d.fail(n, path, "unsupported field type %s", v.Type())
+	}
+}
+
+type pair struct {
+	key string
+	// Key node, for messages
+	at  *yaml.Node
+	val *yaml.Node
+}
+
+// pairs returns the entries of a mapping with merge keys applied:
+// entries of the mapping itself override merged ones, and earlier
+// merge sources override later ones.
+func (d *decoder) pairs(n *yaml.Node, path string) ([]pair, bool) {
+	return d.mergePairs(n, path, map[*yaml.Node]bool{})
+}
+
+// mergePairs is pairs for a mapping merged into those in visiting.
+// A mapping merging itself, directly or through others, is an error
+// rather than endless recursion.
+func (d *decoder) mergePairs(n *yaml.Node, path string, visiting map[*yaml.Node]bool) ([]pair, bool) {
+	at := n
+	n = resolve(n)
+	if n.Kind != yaml.MappingNode {
+		d.fail(n, path, "expected a mapping, found %s", kindName(n))
+		return nil, false
+	}
+	if visiting[n] {
+		d.fail(at, path, "merge key cycle: the mapping is merged into itself")
+		return nil, false
+	}
+	visiting[n] = true
+	defer delete(visiting, n)
+	var own, merged []pair
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, val := n.Content[i], n.Content[i+1]
+		if k.Tag != "!!merge" {
+			own = append(own, pair{k.Value, k, val})
+			continue
+		}
+		srcs := []*yaml.Node{val}
+		if resolve(val).Kind == yaml.SequenceNode {
+			srcs = resolve(val).Content
+		}
+		for _, src := range srcs {
+			if ps, ok := d.mergePairs(src, path, visiting); ok {
+				merged = append(merged, ps...)
+			}
+		}
+	}
+	seen := map[string]bool{}
+	for _, p := range own {
+		seen[p.key] = true
+	}
+	out := own
+	for _, p := range merged {
+		if !seen[p.key] {
+			seen[p.key] = true
+			out = append(out, p)
+		}
+	}
+	return out, true
+}
+
+func resolve(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	return n
+}
+
+func kindName(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	}
+	return strconv.Quote(n.Value)
+}
+
+// jsonFields maps the JSON names of a struct's fields to their
+// indexes, so that YAML files use the keys of the JSON format.
+func jsonFields(t reflect.Type) map[string]int {
+	fields := map[string]int{}
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = i
+		}
+	}
+	return fields
+}
+
+// fieldNames returns the keys of fields, sorted.
+func fieldNames(fields map[string]int) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,149 @@
+package YamlParser
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	ds "go_cmdrX/src/DataStrucs"
+	pf "go_cmdrX/src/Profiles"
+)
+
+const testFile = `
+x-console: &console
+  cmd_type: Console
+  cmd_timeout_in_minutes: 15.0
+  cmd_elements: [cmd.exe, /c]
+commands_batch:
+  jobs_header:
+    log_file_retention_in_days: 7
+    log_path_file_name: ./cmdrx/install.log
+    watch_paths: [src, "*.go"]
+  command_jobs:
+    - <<: *console
+      cmd_display_name: Copy1
+      cmd_elements: [cmd.exe, /c, copy, 'D:\T06\*.*', 'D:\T08\']
+    - <<: *console
+      cmd_display_name: Copy2
+      cmd_timeout_in_minutes: "30"
+      dump_proc_tree_on_idle_timeout: yes
+    - cmd_display_name: Long form
+      cmd_elements:
+        - cmdelement: echo
+        - cmdelement: hi
+  profiles:
+    prod:
+      command_jobs:
+        - cmd_display_name: Copy2
+          cmd_elements: [robocopy, a, b]
+`
+
+func TestDecodeYAMLCmds(t *testing.T) {
+	t.Log("Given a file sharing a job fragment through a merge key:")
+	{
+		b, err := DecodeYAMLCmds(strings.NewReader(testFile), ds.DecodeOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h := b.Batch.Hdr; h.LogFileRetentionInDays != 7 || len(h.WatchPaths) != 2 {
+			t.Errorf("Header %+v", h)
+		}
+		jobs := b.Batch.Jobs
+		if len(jobs) != 3 {
+			t.Fatalf("%d jobs, want 3", len(jobs))
+		}
+		if j := jobs[0]; j.Type != "Console" || j.TimeOutMinutes.Duration != 15*time.Minute || argv(j) != `cmd.exe /c copy D:\T06\*.* D:\T08\` {
+			t.Errorf("Job 1 %+v", j)
+		}
+		if j := jobs[1]; j.TimeOutMinutes.Duration != 30*time.Minute || argv(j) != "cmd.exe /c" || !j.DumpProcTreeOnIdle {
+			t.Errorf("Job 2 %+v", j)
+		}
+		if argv(jobs[2]) != "echo hi" {
+			t.Errorf("Long form elements %v", jobs[2].CmdElements)
+		}
+	}
+
+	t.Log("Given a profile written in YAML:")
+	{
+		b, _ := DecodeYAMLCmds(strings.NewReader(testFile), ds.DecodeOptions{})
+		if err := pf.Apply(&b, "prod", ds.DecodeOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if got := argv(b.Batch.Jobs[1]); got != "robocopy a b" {
+			t.Errorf("Overlay argv %q", got)
+		}
+	}
+
+	t.Log("Given values of the wrong type:")
+	{
+		bad := "commands_batch:\n  jobs_header:\n    log_file_retention_in_days: soon\n  command_jobs:\n    - cmd_elements: {a: b}\n"
+		_, err := DecodeYAMLCmds(strings.NewReader(bad), ds.DecodeOptions{})
+		for _, want := range []string{
+			`line 3, column 33: commands_batch.jobs_header.log_file_retention_in_days: expected an integer, found "soon"`,
+			`line 5, column 21: commands_batch.command_jobs[0].cmd_elements: expected a list, found a mapping`,
+		} {
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("Error %v\nwant %s", err, want)
+			}
+		}
+	}
+
+	t.Log("Given a misspelt key and an annotation:")
+	{
+		doc := "commands_batch:\n  command_jobs:\n    - cmd_display_name: A\n      cmd_timout_in_minutes: 5\n      x-owner: ops\n      cmd_elements: [ls]\n"
+		_, err := DecodeYAMLCmds(strings.NewReader(doc), ds.DecodeOptions{})
+		want := `line 4, column 7: commands_batch.command_jobs[0].cmd_timout_in_minutes: unknown key; did you mean "cmd_timeout_in_minutes"?`
+		if err == nil || err.Error() != want {
+			t.Errorf("Error %v\nwant %s", err, want)
+		}
+		if _, err := DecodeYAMLCmds(strings.NewReader(doc), ds.DecodeOptions{Lenient: true}); err != nil {
+			t.Errorf("Lenient decoding: %v", err)
+		}
+	}
+
+	t.Log("Given a misspelt key in a profile overlay:")
+	{
+		doc := "commands_batch:\n  profiles:\n    prod:\n      jobs_header:\n        log_path_fle_name: p.log\n        x-why: 1\n"
+		_, err := DecodeYAMLCmds(strings.NewReader(doc), ds.DecodeOptions{})
+		want := `line 5, column 9: commands_batch.profiles.prod.jobs_header.log_path_fle_name: unknown key; did you mean "log_path_file_name"?`
+		if err == nil || err.Error() != want {
+			t.Errorf("Error %v\nwant %s", err, want)
+		}
+	}
+
+	t.Log("Given merge keys which form a cycle:")
+	{
+		for _, doc := range []string{
+			"commands_batch: &a\n  <<: *a\n",
+			"commands_batch:\n  jobs_header: &h\n    <<: [*h]\n",
+		} {
+			_, err := DecodeYAMLCmds(strings.NewReader(doc), ds.DecodeOptions{})
+			if err == nil || !strings.Contains(err.Error(), "merge key cycle") {
+				t.Errorf("%q: expected a cycle error, got %v", doc, err)
+			}
+		}
+	}
+
+	t.Log("Given a syntax error:")
+	{
+		if _, err := DecodeYAMLCmds(strings.NewReader("commands_batch:\n  - a\n b: ["), ds.DecodeOptions{}); err == nil || !strings.Contains(err.Error(), "line") {
+			t.Errorf("Error %v", err)
+		}
+	}
+}
+
+func argv(j ds.CmdJob) string {
+	var parts []string
+	for _, e := range j.CmdElements {
+		parts = append(parts, e.CmdUnit)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestCmdElementJSONShortForm(t *testing.T) {
+	var j ds.CmdJob
+	if err := json.Unmarshal([]byte(`{"cmd_elements": ["a", {"cmdelement": "b"}]}`), &j); err != nil || argv(j) != "a b" {
+		t.Errorf("Got %v, %v", j.CmdElements, err)
+	}
+}
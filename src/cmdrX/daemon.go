@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	cr "go_cmdrX/src/CmdRunner"
+	ds "go_cmdrX/src/DataStrucs"
+	eu "go_cmdrX/src/ErrUtil"
+	sc "go_cmdrX/src/Scheduler"
+)
+
+// daemonCmd implements 'cmdrx daemon'. Every command file named on
+// the command line is run on the schedule in its header until the
+// daemon is interrupted.
+func daemonCmd(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	profile := fs.String("profile", "", "apply this profile of every command file")
+	lenient := fs.Bool("lenient", false, "ignore unknown keys in the command files instead of failing")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("daemon: no command files given")
+	}
+
+	lo := loadOpts{profile: *profile, decode: ds.DecodeOptions{Lenient: *lenient}}
+	d := &sc.Daemon{Run: func(ctx context.Context, cmdFile string) error {
+		return runScheduled(ctx, cmdFile, lo)
+	}, Log: os.Stdout}
+	for _, arg := range fs.Args() {
+		fileName, err := filepath.Abs(arg)
+		if err != nil {
+			return err
+		}
+		batch, _, err := loadCmdFile(fileName, lo)
+		if err != nil {
+			return err
+		}
+		hdr := batch.Batch.Hdr
+		if hdr.Schedule == "" {
+			return eu.FileError(eu.Validation, fileName, errors.New("no schedule in jobs_header"))
+		}
+		statePath := strings.TrimSuffix(cr.StatePathFileName(fileName, hdr), ".state.json") + ".schedule.json"
+		e, err := sc.NewEntry(fileName, hdr.Schedule, hdr.ScheduleOverlap, hdr.ScheduleCatchUp, statePath)
+		if err != nil {
+			return eu.FileError(eu.Validation, fileName, err)
+		}
+		d.Entries = append(d.Entries, e)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := d.Loop(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+// runScheduled re-reads the command file, so that edits take effect
+// at the next scheduled run, and runs the batch. Parameters take
+// their declared defaults.
+func runScheduled(ctx context.Context, cmdFile string, lo loadOpts) error {
+	batch, secrets, err := loadCmdFile(cmdFile, lo)
+	if err != nil {
+		return err
+	}
+	_, err = cr.RunBatch(ctx, batch, cr.RunOpts{CmdFile: cmdFile, Secrets: secrets})
+	if err != nil {
+		return &eu.Error{Kind: eu.KindOf(err), Err: errors.New(secretMask.Mask(err.Error()))}
+	}
+	return nil
+}
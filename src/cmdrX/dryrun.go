@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	cr "go_cmdrX/src/CmdRunner"
+)
+
+// dryRunReport is the JSON form of 'cmdrx run --dry-run'.
+type dryRunReport struct {
+	CmdFile string       `json:"cmd_file"`
+	Jobs    []cr.JobPlan `json:"jobs"`
+	Errors  []string     `json:"errors,omitempty"`
+}
+
+// dryRunFormat is the value of --format. It is checked as the flag
+// is parsed, so that a bad format is a usage error.
+type dryRunFormat string
+
+func (f *dryRunFormat) String() string { return string(*f) }
+
+func (f *dryRunFormat) Set(s string) error {
+	if s != "text" && s != "json" {
+		return fmt.Errorf("%q is not text or json", s)
+	}
+	*f = dryRunFormat(s)
+	return nil
+}
+
+// printDryRun writes the resolved job plans as text or JSON. The
+// plans are masked in place first, since quoting and JSON escaping
+// would hide secrets from a masking writer.
+func printDryRun(w io.Writer, format, cmdFile string, plans []cr.JobPlan, planErr error) error {
+	secretMask.MaskStrings(&plans)
+	switch format {
+	case "json":
+		rep := dryRunReport{CmdFile: cmdFile, Jobs: plans}
+		if planErr != nil {
+			rep.Errors = strings.Split(planErr.Error(), "\n")
+			secretMask.MaskStrings(&rep.Errors)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	case "text":
+		fmt.Fprintf(w, "Dry run of %s (start times assume zero job duration)\n", cmdFile)
+		for _, p := range plans {
+			fmt.Fprintln(w, "=======================================")
+			fmt.Fprintf(w, "Cmd-%d %s [%s]\n", p.Index, p.DisplayName, p.Type)
+			fmt.Fprintf(w, "  argv:         %s\n", quoteArgv(p.Argv))
+			fmt.Fprintf(w, "  dir:          %s\n", p.Dir)
+			fmt.Fprintf(w, "  env:          %s\n", listOrNone(p.EnvDiff()))
+			if p.LogPath != "" {
+				fmt.Fprintf(w, "  log:          %s\n", p.LogPath)
+			}
+			fmt.Fprintf(w, "  start at:     %s\n", p.StartAt.Format(time.RFC3339))
+			fmt.Fprintf(w, "  timeout:      %s\n", p.TimeOutStr)
+			fmt.Fprintf(w, "  idle timeout: %s\n", p.IdleTimeOutStr)
+			fmt.Fprintf(w, "  exit codes:   %s\n", p.ExitCodes)
+		}
+		fmt.Fprintln(w, "=======================================")
+		if planErr != nil {
+			fmt.Fprintf(w, "Errors:\n%s\n", planErr)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown dry run format %q", format)
+}
+
+func quoteArgv(argv []string) string {
+	q := make([]string, len(argv))
+	for i, a := range argv {
+		q[i] = strconv.Quote(a)
+	}
+	return "[" + strings.Join(q, " ") + "]"
+}
+
+func listOrNone(l []string) string {
+	if len(l) == 0 {
+		return "(unchanged)"
+	}
+	return strings.Join(l, " ")
+}
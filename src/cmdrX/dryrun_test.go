@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cr "go_cmdrX/src/CmdRunner"
+)
+
+func TestSecretsMaskedWhenEscaped(t *testing.T) {
+	const secret = "ab<c>&\"d\\x\t\x01y"
+	t.Setenv("MYTOK", secret)
+	cmdFile := filepath.Join(t.TempDir(), "batch.json")
+	os.WriteFile(cmdFile, []byte(`{"commands_batch": {
+  "jobs_header": {"secrets": [{"name": "TOK", "from_env": "MYTOK"}]},
+  "command_jobs": [{"cmd_display_name": "Push", "cmd_type": "Console", "secrets": ["TOK"],
+    "cmd_elements": ["push", "--token=%(SECRET:TOK)%"]}]}}`), 0644)
+	batch, secrets, err := loadCmdFile(cmdFile, loadOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Fragments of the secret as its escaped forms would show it
+	leaks := []string{"ab<c>", `ab<c`, `\"d\\x`, `d\\x\t`, `\u0001y`}
+
+	t.Log("Given a secret holding characters which quoting escapes:")
+	{
+		for _, format := range []string{"text", "json"} {
+			r := cr.Runner{Batch: batch, Secrets: secrets}
+			plans, planErr := r.Plan(time.Now())
+			if planErr != nil {
+				t.Fatal(planErr)
+			}
+			var out bytes.Buffer
+			if err := printDryRun(&out, format, cmdFile, plans, nil); err != nil {
+				t.Fatal(err)
+			}
+			checkMasked(t, "dry run "+format, out.String(), leaks)
+			if n := strings.Count(out.String(), "********"); n < 2 {
+				t.Errorf("dry run %s: argv and env not both masked:\n%s", format, out.String())
+			}
+		}
+		var out bytes.Buffer
+		if err := printBatch(&out, batch); err != nil {
+			t.Fatal(err)
+		}
+		checkMasked(t, "show", out.String(), leaks)
+	}
+}
+
+func checkMasked(t *testing.T, what, out string, leaks []string) {
+	t.Helper()
+	for _, l := range leaks {
+		if strings.Contains(out, l) {
+			t.Errorf("%s: secret fragment %q in output:\n%s", what, l, out)
+		}
+	}
+}
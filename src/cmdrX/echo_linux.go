@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// noEcho turns off the echo of f when it is a terminal, returning
+// the function restoring it, or nil when f is not a terminal.
+func noEcho(f *os.File) func() {
+	fd := f.Fd()
+	var old syscall.Termios
+	if ioctl(fd, syscall.TCGETS, &old) != nil {
+		return nil
+	}
+	t := old
+	t.Lflag &^= syscall.ECHO
+	if ioctl(fd, syscall.TCSETS, &t) != nil {
+		return nil
+	}
+	return func() { ioctl(fd, syscall.TCSETS, &old) }
+}
+
+func ioctl(fd, req uintptr, t *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(unsafe.Pointer(t))); errno != 0 {
+		return errno
+	}
+	return nil
+}
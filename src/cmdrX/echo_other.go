@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+// noEcho is only supported on Linux; elsewhere the value is echoed.
+func noEcho(f *os.File) func() { return nil }
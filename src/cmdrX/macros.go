@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	ds "go_cmdrX/src/DataStrucs"
+	ld "go_cmdrX/src/Loader"
+	mc "go_cmdrX/src/Macros"
+)
+
+// macrosCmd implements 'cmdrx macros'. It lists the built-in macros
+// with the values they would have in a run of the command file
+// started now. The file only supplies the macro_*_format layouts;
+// without one the defaults are shown.
+func macrosCmd(args []string) error {
+	fs := flag.NewFlagSet("macros", flag.ExitOnError)
+	lenient := fs.Bool("lenient", false, "ignore unknown keys in the command file instead of failing")
+	fs.Parse(args)
+
+	fileName := cmdFileArg(fs.Args())
+	var hdr ds.CmdHdrDat
+	if _, err := os.Stat(fileName); err == nil {
+		batch, err := ld.Load(fileName, ds.DecodeOptions{Lenient: *lenient})
+		if err != nil {
+			return err
+		}
+		hdr = batch.Batch.Hdr
+	} else if fs.NArg() > 0 {
+		return err
+	}
+	mctx := mc.NewContext(fileName, hdr, time.Now())
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "MACRO\tVALUE\tDESCRIPTION")
+	for _, m := range mc.List() {
+		usage := "%(" + m.Name + ")%"
+		if m.Arg != "" {
+			usage = "%(" + m.Name + "[:" + m.Arg + "])%"
+		}
+		if m.ArgRequired {
+			usage = "%(" + m.Name + ":" + m.Arg + ")%"
+		}
+		value := "(needs an argument)"
+		if !m.ArgRequired {
+			v, err := mctx.Value(m.Name, "")
+			if err != nil {
+				v = "error: " + err.Error()
+			}
+			value = v
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", usage, value, m.Desc)
+	}
+	fmt.Fprintln(tw, "%%(\t%(\tescape: a literal %(")
+	return tw.Flush()
+}
@@ -1,25 +1,227 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	jp "go_cmdrX/src/JsonParser"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	ds "go_cmdrX/src/DataStrucs"
+	eu "go_cmdrX/src/ErrUtil"
+	ld "go_cmdrX/src/Loader"
+	mc "go_cmdrX/src/Macros"
+	pf "go_cmdrX/src/Profiles"
+	sx "go_cmdrX/src/Secrets"
 )
 
+// Note: relative command file path is determined by reference
+// to main.go path. The first existing file with this base name
+// and a supported extension is used, JSON by default.
+const defaultCmdFileBase = "./CmdrX_Cmds"
+
+const usage = `Usage: cmdrx <command> [flags] [command-file] [-p name=value]... [--profile name]
+                     [--lenient]
+
+Commands:
+  run    execute the jobs in a command file (default);
+         --dry-run prints the resolved jobs instead
+  watch  run a command file, then re-run jobs whose inputs change
+  daemon run command files on the schedules in their headers
+  macros list the %(NAME)% macros and their values
+  show   print a command file with its profile applied
+  migrate
+         rewrite command files of an earlier schema version
+  schema print the JSON Schema of command files
+  validate
+         check JSON command files against the schema
+  secrets
+         maintain an encrypted secrets file
+
+The command file may be JSON, YAML, TOML or an XML file of the older
+CmdrX tool. Its format is taken from the extension or detected from
+the content.
+
+Exit status:
+  0  success
+  1  any other error
+  2  command line usage error
+  3  command file, or the state file to resume from, not found
+  4  command file does not parse, or has unknown keys
+  5  command file is not valid: a missing directory, parameter,
+     secret or macro, or a bad setting
+  6  a job could not be started
+  7  a job timed out or went idle
+  8  a job's exit code passed its kill_jobs_on_exit_code thresholds
+  9  a job was cancelled
+`
+
 func main() {
-	// Note: relative JSON file path is determined by reference
-	// to main.go path.
-	fileName := "./CmdrX_Cmds.json"
-	jObj := jp.ParseJSONCmds(fileName)
-	fmt.Println(jObj)
-	fmt.Println("=======================================")
-	fmt.Println("Header")
-	fmt.Println("Log File Retention In Days:", jObj.Batch.Hdr.LogFileRetentionInDays)
-	fmt.Println("=======================================")
-	fmt.Println("Command 1")
-	fmt.Println("Cmd-1 Display Name:", jObj.Batch.Jobs[0].DisplayName)
-	fmt.Println("Cmn-1 Cmd Element-1:", jObj.Batch.Jobs[0].CmdElements[0].CmdUnit)
-	fmt.Println("=======================================")
-	fmt.Println("Command 2")
-	fmt.Println("Cmd-2 Display Name:", jObj.Batch.Jobs[1].DisplayName)
-	fmt.Println("Cmn-2 Cmd Element-1:", jObj.Batch.Jobs[1].CmdElements[0].CmdUnit)
+	args := os.Args[1:]
+	cmd := "run"
+	if len(args) > 0 && !isCmdFileArg(args[0]) {
+		cmd, args = args[0], args[1:]
+	}
+	var err error
+	switch cmd {
+	case "run":
+		err = runCmd(args)
+	case "watch":
+		err = watchCmd(args)
+	case "daemon":
+		err = daemonCmd(args)
+	case "macros":
+		err = macrosCmd(args)
+	case "show":
+		err = showCmd(args)
+	case "secrets":
+		err = secretsCmd(args)
+	case "migrate":
+		err = migrateCmd(args)
+	case "schema":
+		err = schemaCmd(args)
+	case "validate":
+		err = validateCmd(args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "cmdrx: unknown command %q\n%s", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cmdrx:", secretMask.Mask(err.Error()))
+		os.Exit(exitCodes[eu.KindOf(err)])
+	}
+}
+
+// exitCodes are the exit statuses of the error kinds, as listed in
+// the usage text. Status 2 is that of usage errors, as with the
+// flag package.
+var exitCodes = map[eu.Kind]int{
+	eu.Other:        1,
+	eu.FileNotFound: 3,
+	eu.Parse:        4,
+	eu.Validation:   5,
+	eu.Launch:       6,
+	eu.Timeout:      7,
+	eu.ExitCode:     8,
+	eu.Cancelled:    9,
+}
+
+// isCmdFileArg reports whether the first argument names a command
+// file or a flag of the default run command rather than a command.
+func isCmdFileArg(arg string) bool {
+	if len(arg) > 1 && arg[0] == '-' && arg != "-h" && arg != "--help" {
+		return true
+	}
+	_, err := os.Stat(arg)
+	return err == nil
+}
+
+// cmdFileArg returns the command file named on the command line,
+// or the default command file.
+func cmdFileArg(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	for _, ext := range ld.Extensions() {
+		if _, err := os.Stat(defaultCmdFileBase + ext); err == nil {
+			return defaultCmdFileBase + ext
+		}
+	}
+	return defaultCmdFileBase + ".json"
+}
+
+// parseInterspersed parses flags which may follow the command file,
+// as in 'cmdrx run file.json -p name=value', and returns the
+// positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// paramFlags collects repeated -p name=value flags.
+type paramFlags map[string]string
+
+func (p paramFlags) String() string {
+	var kv []string
+	for k, v := range p {
+		kv = append(kv, k+"="+v)
+	}
+	return strings.Join(kv, " ")
+}
+
+func (p paramFlags) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("%q is not name=value", s)
+	}
+	p[name] = value
+	return nil
+}
+
+// secretMask collects the values of every secret resolved by this
+// process, so that they can be masked in anything it prints.
+var secretMask = &sx.Masker{}
+
+// loadOpts are the command line settings applied to a command file
+// as it is loaded.
+type loadOpts struct {
+	params  map[string]string
+	profile string
+	decode  ds.DecodeOptions
+}
+
+// addFlags registers -p, --profile and --lenient.
+func (o *loadOpts) addFlags(fs *flag.FlagSet) {
+	o.params = paramFlags{}
+	fs.Var(paramFlags(o.params), "p", "set a parameter, name=value; may be repeated")
+	fs.StringVar(&o.profile, "profile", "", "apply this profile of the command file")
+	fs.BoolVar(&o.decode.Lenient, "lenient", false, "ignore unknown keys in the command file instead of failing")
+}
+
+// parseCmdFile parses a command file and applies the selected
+// profile.
+func parseCmdFile(fileName string, o loadOpts) (batch ds.JsonCmdBatch, err error) {
+	if batch, err = ld.Load(fileName, o.decode); err != nil {
+		return batch, err
+	}
+	if err := pf.Apply(&batch, o.profile, o.decode); err != nil {
+		return batch, eu.FileError(eu.Validation, fileName, err)
+	}
+	return batch, nil
+}
+
+// loadCmdFile parses a command file, applies the selected profile,
+// resolves its parameters from the command line and the declared
+// defaults, and expands its macros. The returned store resolves the
+// file's secrets.
+func loadCmdFile(fileName string, o loadOpts) (batch ds.JsonCmdBatch, secrets *sx.Store, err error) {
+	if batch, err = parseCmdFile(fileName, o); err != nil {
+		return batch, nil, err
+	}
+	mctx := mc.NewContext(fileName, batch.Batch.Hdr, time.Now())
+	if mctx.Params, err = mc.ResolveParams(batch.Batch.Params, o.params); err != nil {
+		return batch, nil, eu.FileError(eu.Validation, fileName, err)
+	}
+	if err := mctx.ExpandHeader(&batch.Batch.Hdr); err != nil {
+		return batch, nil, eu.FileError(eu.Validation, fileName, err)
+	}
+	secrets, err = sx.NewStore(batch.Batch.Hdr.Secrets, filepath.Dir(mctx.CmdFile), secretMask)
+	if err != nil {
+		return batch, nil, eu.FileError(eu.Validation, fileName, err)
+	}
+	mctx.Secrets = secrets.Get
+	if err := mctx.ExpandJobs(batch.Batch.Jobs); err != nil {
+		return batch, nil, eu.FileError(eu.Validation, fileName, err)
+	}
+	return batch, secrets, nil
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	ds "go_cmdrX/src/DataStrucs"
+	eu "go_cmdrX/src/ErrUtil"
+	jp "go_cmdrX/src/JsonParser"
+	ld "go_cmdrX/src/Loader"
+)
+
+// migrateCmd implements 'cmdrx migrate'. It rewrites JSON command
+// files of an earlier schema version in the current one and prints
+// a diff of each change. The original is kept as FILE.bak.
+func migrateCmd(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "print the diffs without rewriting the files")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: cmdrx migrate [--dry-run] command-file...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("migrate: no command files named")
+	}
+	var errs []error
+	for _, fileName := range fs.Args() {
+		if err := migrateFile(os.Stdout, fileName, *dryRun); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", fileName, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func migrateFile(w io.Writer, fileName string, dryRun bool) error {
+	orig, err := os.ReadFile(fileName)
+	if err != nil {
+		return eu.ReadError(err)
+	}
+	if l, err := ld.Detect(fileName, orig); err != nil {
+		return err
+	} else if l.Name() != "json" {
+		return fmt.Errorf("only JSON command files have schema versions; this is %s", l.Name())
+	}
+	data := bytes.TrimPrefix(orig, []byte("\xEF\xBB\xBF"))
+	v, err := jp.SchemaVersion(data)
+	if err != nil {
+		return &eu.Error{Kind: eu.Parse, Err: err}
+	}
+	if v == ds.CurrentSchemaVersion {
+		fmt.Fprintf(w, "%s: already at schema version %d\n", fileName, v)
+		return nil
+	}
+	batch, err := jp.DecodeJSONCmds(bytes.NewReader(data), ds.DecodeOptions{})
+	if err != nil {
+		return &eu.Error{Kind: eu.Parse, Err: err}
+	}
+	out, err := jp.EncodeJSONCmds(batch)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(w, unifiedDiff(fileName, fmt.Sprintf("%s (schema version %d)", fileName, ds.CurrentSchemaVersion),
+		string(data), string(out)))
+	if dryRun {
+		return nil
+	}
+	st, err := os.Stat(fileName)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(fileName+".bak", orig, st.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := os.WriteFile(fileName, out, st.Mode().Perm()); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s: migrated from schema version %d to %d; original kept as %s.bak\n",
+		fileName, v, ds.CurrentSchemaVersion, fileName)
+	return nil
+}
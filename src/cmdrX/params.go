@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	ds "go_cmdrX/src/DataStrucs"
+	ld "go_cmdrX/src/Loader"
+	mc "go_cmdrX/src/Macros"
+)
+
+// printParams implements 'cmdrx run --help-params'.
+func printParams(w io.Writer, fileName string, opts ds.DecodeOptions) error {
+	batch, err := ld.Load(fileName, opts)
+	if err != nil {
+		return err
+	}
+	params := batch.Batch.Params
+	if len(params) == 0 {
+		fmt.Fprintf(w, "%s declares no parameters\n", fileName)
+		return nil
+	}
+	fmt.Fprintf(w, "Parameters of %s, set with -p name=value:\n\n", fileName)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tDEFAULT\tDESCRIPTION")
+	for _, p := range params {
+		typ := mc.ParamType(p)
+		if typ == mc.ParamEnum {
+			typ += " (" + strings.Join(p.Values, "|") + ")"
+		}
+		def := p.Default
+		switch {
+		case p.Required:
+			def = "(required)"
+		case def == "" && typ == mc.ParamString:
+			def = `""`
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.Name, typ, def, p.Desc)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	cr "go_cmdrX/src/CmdRunner"
+	eu "go_cmdrX/src/ErrUtil"
+)
+
+// runCmd implements 'cmdrx run'.
+func runCmd(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	resume := fs.Bool("resume", false, "skip jobs which succeeded in the last run and continue from the first failed job")
+	force := fs.Bool("force", false, "run jobs even when their inputs are up to date")
+	dryRun := fs.Bool("dry-run", false, "resolve and print every job without launching anything")
+	format := dryRunFormat("text")
+	fs.Var(&format, "format", "dry run output format: text or json")
+	helpParams := fs.Bool("help-params", false, "list the parameters of the command file")
+	var lo loadOpts
+	lo.addFlags(fs)
+	fileName := cmdFileArg(parseInterspersed(fs, args))
+
+	if *helpParams {
+		return printParams(os.Stdout, fileName, lo.decode)
+	}
+	jObj, secrets, err := loadCmdFile(fileName, lo)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		r := cr.Runner{Batch: jObj, Secrets: secrets}
+		plans, planErr := r.Plan(time.Now())
+		out := secretMask.Writer(os.Stdout)
+		defer out.Flush()
+		if err := printDryRun(out, string(format), fileName, plans, planErr); err != nil {
+			return err
+		}
+		if planErr != nil {
+			return &eu.Error{Kind: eu.KindOf(planErr), Err: errors.New("dry run found errors")}
+		}
+		return nil
+	}
+	opts := cr.RunOpts{CmdFile: fileName, Resume: *resume, Force: *force, Secrets: secrets}
+	results, err := cr.RunBatch(context.Background(), jObj, opts)
+	printResults(results)
+	if err != nil {
+		return fmt.Errorf("batch stopped: %w", err)
+	}
+	return nil
+}
+
+func printResults(results []cr.JobResult) {
+	if len(results) == 0 {
+		return
+	}
+	fmt.Println("=======================================")
+	for i, r := range results {
+		status := fmt.Sprintf("exit code %d", r.ExitCode)
+		if r.Skipped {
+			status = "skipped"
+		}
+		if r.UpToDate {
+			status = "up-to-date"
+		}
+		fmt.Printf("Cmd-%d %s: %s\n", i+1, secretMask.Mask(r.DisplayName), status)
+	}
+	fmt.Println("=======================================")
+}
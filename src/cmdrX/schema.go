@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	ds "go_cmdrX/src/DataStrucs"
+	eu "go_cmdrX/src/ErrUtil"
+	jp "go_cmdrX/src/JsonParser"
+	js "go_cmdrX/src/JsonSchema"
+	ld "go_cmdrX/src/Loader"
+)
+
+// schemaCmd implements 'cmdrx schema', printing the JSON Schema of
+// command files for editors to use.
+func schemaCmd(args []string) error {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: cmdrx schema > cmdrx.schema.json")
+	}
+	fs.Parse(args)
+	_, err := os.Stdout.Write(js.Schema)
+	return err
+}
+
+// validateCmd implements 'cmdrx validate', checking JSON command
+// files against the schema. Each problem is printed with its line,
+// column and JSON pointer.
+func validateCmd(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: cmdrx validate [command-file...]")
+	}
+	fs.Parse(args)
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{cmdFileArg(nil)}
+	}
+	var errs []error
+	for _, fileName := range files {
+		n, err := validateFile(os.Stdout, fileName)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s: %w", fileName, err))
+		case n > 0:
+			errs = append(errs, eu.FileError(eu.Validation, fileName, fmt.Errorf("%d problems found", n)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateFile prints the schema problems of a file and returns
+// their number.
+func validateFile(w io.Writer, fileName string) (int, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return 0, eu.ReadError(err)
+	}
+	if l, err := ld.Detect(fileName, data); err != nil {
+		return 0, err
+	} else if l.Name() != "json" {
+		return 0, fmt.Errorf("the schema describes JSON command files; this is %s", l.Name())
+	}
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	problems, err := js.Validate(data)
+	if err != nil {
+		return 0, &eu.Error{Kind: eu.Parse, Err: err}
+	}
+	if len(problems) > 0 {
+		if v, err := jp.SchemaVersion(data); err == nil && v < ds.CurrentSchemaVersion {
+			return 0, fmt.Errorf("schema version %d file; run 'cmdrx migrate %s' first", v, fileName)
+		}
+	}
+	for _, p := range problems {
+		fmt.Fprintf(w, "%s:%s\n", fileName, p)
+	}
+	if len(problems) == 0 {
+		fmt.Fprintf(w, "%s: valid\n", fileName)
+	}
+	return len(problems), nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	sx "go_cmdrX/src/Secrets"
+)
+
+const secretsUsage = `Usage: cmdrx secrets set FILE NAME     (value read from stdin)
+       cmdrx secrets delete FILE NAME
+       cmdrx secrets list FILE
+The passphrase is taken from ` + sx.PassphraseEnv + `.
+`
+
+// secretsCmd implements 'cmdrx secrets', which maintains encrypted
+// secrets files referenced by from_encrypted_file.
+func secretsCmd(args []string) error {
+	if len(args) < 2 {
+		return errors.New("secrets: missing arguments\n" + secretsUsage)
+	}
+	op, file := args[0], args[1]
+	pass, ok := os.LookupEnv(sx.PassphraseEnv)
+	if !ok || pass == "" {
+		return fmt.Errorf("secrets: %s is not set", sx.PassphraseEnv)
+	}
+	vals, err := sx.LoadEncrypted(file, pass)
+	if err != nil {
+		return fmt.Errorf("secrets: %v", err)
+	}
+	switch {
+	case op == "list" && len(args) == 2:
+		var names []string
+		for n := range vals {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			fmt.Println(n)
+		}
+		return nil
+	case op == "set" && len(args) == 3:
+		fmt.Fprintf(os.Stderr, "Value of %s: ", args[2])
+		restore := noEcho(os.Stdin)
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if restore != nil {
+			restore()
+			fmt.Fprintln(os.Stderr)
+		}
+		if err != nil && line == "" {
+			return fmt.Errorf("secrets: reading value: %v", err)
+		}
+		vals[args[2]] = strings.TrimRight(line, "\r\n")
+	case op == "delete" && len(args) == 3:
+		if _, ok := vals[args[2]]; !ok {
+			return fmt.Errorf("secrets: no entry %q in %s", args[2], file)
+		}
+		delete(vals, args[2])
+	default:
+		return errors.New("secrets: bad arguments\n" + secretsUsage)
+	}
+	if err := sx.SaveEncrypted(file, pass, vals); err != nil {
+		return fmt.Errorf("secrets: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	ds "go_cmdrX/src/DataStrucs"
+	ld "go_cmdrX/src/Loader"
+	pf "go_cmdrX/src/Profiles"
+)
+
+// showCmd implements 'cmdrx show'. It prints the command file as
+// JSON with the selected profile merged in. With --expand the
+// parameters and macros are resolved too, with secrets masked.
+func showCmd(args []string) error {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	expand := fs.Bool("expand", false, "also resolve parameters and expand macros")
+	list := fs.Bool("list-profiles", false, "list the profiles of the command file")
+	var lo loadOpts
+	lo.addFlags(fs)
+	fileName := cmdFileArg(parseInterspersed(fs, args))
+
+	if *list {
+		batch, err := ld.Load(fileName, lo.decode)
+		if err != nil {
+			return err
+		}
+		names := pf.Names(batch.Batch.Profiles)
+		if len(names) == 0 {
+			fmt.Printf("%s has no profiles\n", fileName)
+			return nil
+		}
+		fmt.Println(strings.Join(names, "\n"))
+		return nil
+	}
+	var batch ds.JsonCmdBatch
+	var err error
+	if *expand {
+		batch, _, err = loadCmdFile(fileName, lo)
+	} else {
+		batch, err = parseCmdFile(fileName, lo)
+	}
+	if err != nil {
+		return err
+	}
+	return printBatch(os.Stdout, batch)
+}
+
+// printBatch writes the batch as JSON with secrets masked. They are
+// masked before encoding, as JSON escaping would hide them from a
+// masking writer.
+func printBatch(w io.Writer, batch ds.JsonCmdBatch) error {
+	secretMask.MaskStrings(&batch)
+	out := secretMask.Writer(w)
+	defer out.Flush()
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(batch)
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	cr "go_cmdrX/src/CmdRunner"
+	ds "go_cmdrX/src/DataStrucs"
+	fw "go_cmdrX/src/FileWatch"
+	sx "go_cmdrX/src/Secrets"
+)
+
+// watchCmd implements 'cmdrx watch'. It runs the batch, then
+// re-runs the jobs whose inputs change. A run in progress is
+// cancelled when new changes arrive.
+func watchCmd(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	debounce := fs.Duration("debounce", 500*time.Millisecond, "quiet period which ends a burst of changes")
+	var lo loadOpts
+	lo.addFlags(fs)
+
+	fileName, err := filepath.Abs(cmdFileArg(parseInterspersed(fs, args)))
+	if err != nil {
+		return err
+	}
+	ws := &watchSession{cmdFile: fileName, debounce: *debounce, opts: lo}
+	if ws.batch, ws.secrets, err = loadCmdFile(fileName, lo); err != nil {
+		return err
+	}
+	return ws.loop()
+}
+
+type watchSession struct {
+	cmdFile  string
+	debounce time.Duration
+	opts     loadOpts
+	batch    ds.JsonCmdBatch
+	secrets  *sx.Store
+}
+
+func (ws *watchSession) loop() error {
+	w, err := fw.NewWatcher(ws.watchPatterns())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if w != nil {
+			w.Close()
+		}
+	}()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	var (
+		cancel   context.CancelFunc
+		runDone  chan struct{}
+		running  map[int]bool
+		pending  = map[string]bool{}
+		quiet    <-chan time.Time
+		selected = ws.allJobs()
+	)
+	start := func(sel map[int]bool) {
+		ctx, c := context.WithCancel(context.Background())
+		cancel, runDone, running = c, make(chan struct{}), sel
+		go ws.run(ctx, ws.batch, ws.secrets, sel, runDone)
+	}
+	start(selected)
+
+	for {
+		select {
+		case <-interrupt:
+			if cancel != nil {
+				cancel()
+				<-runDone
+			}
+			return nil
+		case err := <-w.Errors:
+			fmt.Fprintln(os.Stderr, "cmdrx watch:", err)
+		case p := <-w.Events:
+			if ws.isRelevant(p) {
+				pending[p] = true
+				quiet = time.After(ws.debounce)
+			}
+		case <-runDone:
+			cancel, runDone, running = nil, nil, nil
+		case <-quiet:
+			quiet = nil
+			changed := pending
+			pending = map[string]bool{}
+			if changed[ws.cmdFile] {
+				batch, secrets, err := loadCmdFile(ws.cmdFile, ws.opts)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "cmdrx watch: keeping previous command file:", secretMask.Mask(err.Error()))
+					continue
+				}
+				ws.batch, ws.secrets = batch, secrets
+				fmt.Println("Command file reloaded:", ws.cmdFile)
+				// The watches of the previous command file are kept
+				//   when those of the new one cannot be set.
+				if nw, err := fw.NewWatcher(ws.watchPatterns()); err != nil {
+					fmt.Fprintln(os.Stderr, "cmdrx watch: keeping previous watches:", err)
+				} else {
+					w.Close()
+					w = nw
+				}
+			}
+			sel := ws.affectedJobs(changed)
+			if len(sel) == 0 {
+				continue
+			}
+			if cancel != nil {
+				fmt.Println("Changes detected; cancelling the run in progress")
+				cancel()
+				<-runDone
+				if !changed[ws.cmdFile] {
+					for i := range running {
+						sel[i] = true
+					}
+				}
+			}
+			start(sel)
+		}
+	}
+}
+
+func (ws *watchSession) run(ctx context.Context, batch ds.JsonCmdBatch, secrets *sx.Store, sel map[int]bool, done chan struct{}) {
+	defer close(done)
+	fmt.Printf("Running %d job(s) at %s\n", len(sel), time.Now().Format(time.Kitchen))
+	opts := cr.RunOpts{CmdFile: ws.cmdFile, Only: sel, Secrets: secrets}
+	results, err := cr.RunBatch(ctx, batch, opts)
+	printResults(results)
+	if err != nil {
+		fmt.Println("Batch stopped:", secretMask.Mask(err.Error()))
+	}
+	fmt.Println("Watching for changes...")
+}
+
+func (ws *watchSession) allJobs() map[int]bool {
+	sel := map[int]bool{}
+	for i := range ws.batch.Batch.Jobs {
+		sel[i] = true
+	}
+	return sel
+}
+
+// jobDir returns a job's absolute working directory.
+func (ws *watchSession) jobDir(job ds.CmdJob) string {
+	r := cr.Runner{Batch: ws.batch}
+	dir, _ := filepath.Abs(r.JobDir(job))
+	return dir
+}
+
+func (ws *watchSession) hdrPatterns() []string {
+	base, _ := filepath.Abs(ws.batch.Batch.Hdr.CmdExeDirectory)
+	return resolvePatterns(base, ws.batch.Batch.Hdr.WatchPaths)
+}
+
+func (ws *watchSession) watchPatterns() []string {
+	patterns := append([]string{ws.cmdFile}, ws.hdrPatterns()...)
+	for _, job := range ws.batch.Batch.Jobs {
+		patterns = append(patterns, resolvePatterns(ws.jobDir(job), job.Inputs)...)
+	}
+	return patterns
+}
+
+// isRelevant filters out changes to files the batch writes itself:
+// job outputs, the batch and job logs, the state file and the input
+// manifest.
+func (ws *watchSession) isRelevant(path string) bool {
+	if path == ws.cmdFile {
+		return true
+	}
+	hdr := ws.batch.Batch.Hdr
+	owned := []string{hdr.LogPathFileName, cr.StatePathFileName(ws.cmdFile, hdr),
+		cr.ManifestPathFileName(ws.cmdFile, hdr)}
+	for _, job := range ws.batch.Batch.Jobs {
+		owned = append(owned, job.LogPathFileName)
+	}
+	for _, own := range owned {
+		if own == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(own); err == nil && (path == abs || path == abs+".tmp") {
+			return false
+		}
+	}
+	for _, job := range ws.batch.Batch.Jobs {
+		if matchesAny(path, resolvePatterns(ws.jobDir(job), job.Outputs)) {
+			return false
+		}
+	}
+	return true
+}
+
+// affectedJobs returns the jobs to re-run for a set of changed
+// paths. A change to the command file or a header watch path
+// selects every job; jobs without declared inputs always run.
+func (ws *watchSession) affectedJobs(changed map[string]bool) map[int]bool {
+	hdr := ws.hdrPatterns()
+	for p := range changed {
+		if p == ws.cmdFile || matchesAny(p, hdr) {
+			return ws.allJobs()
+		}
+	}
+	sel := map[int]bool{}
+	hit := false
+	for i, job := range ws.batch.Batch.Jobs {
+		if len(job.Inputs) == 0 {
+			sel[i] = true
+			continue
+		}
+		inputs := resolvePatterns(ws.jobDir(job), job.Inputs)
+		for p := range changed {
+			if matchesAny(p, inputs) {
+				sel[i], hit = true, true
+				break
+			}
+		}
+	}
+	if !hit {
+		return nil
+	}
+	return sel
+}
+
+func resolvePatterns(dir string, patterns []string) []string {
+	var out []string
+	for _, p := range patterns {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// matchesAny reports whether path, or a directory above it, matches
+// one of the patterns.
+func matchesAny(path string, patterns []string) bool {
+	for _, pattern := range patterns {
+		for p := path; ; p = filepath.Dir(p) {
+			if ok, _ := filepath.Match(pattern, p); ok {
+				return true
+			}
+			if filepath.Dir(p) == p {
+				break
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	ds "go_cmdrX/src/DataStrucs"
+)
+
+func TestWatchIgnoresOwnFiles(t *testing.T) {
+	dir := t.TempDir()
+	ws := &watchSession{cmdFile: filepath.Join(dir, "batch.json")}
+	ws.batch.Batch.Hdr.CmdExeDirectory = dir
+	ws.batch.Batch.Hdr.LogPathFileName = filepath.Join(dir, "batch.log")
+	ws.batch.Batch.Jobs = []ds.CmdJob{{DisplayName: "Build", Inputs: []string{"."},
+		Outputs: []string{"out/*"}, LogPathFileName: filepath.Join(dir, "build.log")}}
+
+	t.Log("Given a job whose log and outputs are below its inputs:")
+	{
+		for path, want := range map[string]bool{
+			ws.cmdFile:                             true,
+			filepath.Join(dir, "main.c"):           true,
+			filepath.Join(dir, "batch.log"):        false,
+			filepath.Join(dir, "build.log"):        false,
+			filepath.Join(dir, "build.log.tmp"):    false,
+			filepath.Join(dir, "batch.state.json"): false,
+			filepath.Join(dir, "out", "app"):       false,
+		} {
+			if got := ws.isRelevant(path); got != want {
+				t.Errorf("isRelevant(%s) = %v, expected %v", filepath.Base(path), got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Package suggest finds the intended word for a misspelt one, for
+// "did you mean" messages.
+package suggest
+
+import "fmt"
+
+// Distance returns the edit distance of a and b: the fewest single
+// character insertions, deletions and substitutions turning one
+// into the other.
+func Distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// Closest returns the candidate nearest to word by edit distance,
+// the earliest of equally near ones. It returns "" when none is
+// near enough to be a likely misspelling: within a third of the
+// word's length, and at least 2 edits.
+func Closest(word string, candidates []string) string {
+	best, bestDist := "", max(2, len([]rune(word))/3)+1
+	for _, c := range candidates {
+		if d := Distance(word, c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// DidYouMean returns `; did you mean "x"?` naming the closest
+// candidate to word, or "" when there is none.
+func DidYouMean(word string, candidates []string) string {
+	if c := Closest(word, candidates); c != "" {
+		return fmt.Sprintf("; did you mean %q?", c)
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package suggest
+
+import "testing"
+
+func TestDistance(t *testing.T) {
+	t.Log("Given pairs of words:")
+	{
+		for _, c := range []struct {
+			a, b string
+			want int
+		}{
+			{"", "", 0},
+			{"abc", "", 3},
+			{"kitten", "sitting", 3},
+			{"cmd_timout_in_minutes", "cmd_timeout_in_minutes", 1},
+			{"délai", "delai", 1},
+		} {
+			if got := Distance(c.a, c.b); got != c.want {
+				t.Errorf("Distance(%q, %q) = %d, expected %d", c.a, c.b, got, c.want)
+			}
+		}
+	}
+}
+
+func TestClosest(t *testing.T) {
+	names := []string{"cmd_display_name", "cmd_timeout_in_minutes", "cmd_idle_timeout_in_minutes", "cmd_type"}
+	t.Log("Given misspelt and unrelated keys:")
+	{
+		for word, want := range map[string]string{
+			"cmd_timout_in_minutes": "cmd_timeout_in_minutes",
+			"CMD_TYPE":              "",
+			"cmd_typ":               "cmd_type",
+			"cmd_dispaly_name":      "cmd_display_name",
+			"comment":               "",
+		} {
+			if got := Closest(word, names); got != want {
+				t.Errorf("Closest(%q) = %q, expected %q", word, got, want)
+			}
+		}
+		if got := DidYouMean("cmd_typ", names); got != `; did you mean "cmd_type"?` {
+			t.Errorf("DidYouMean gave %q", got)
+		}
+	}
+}